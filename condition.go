@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var conditionUnits = map[string]float64{
+	"s":  1,
+	"m":  60,
+	"h":  3600,
+	"d":  86400,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+}
+
+type conditionEnv struct {
+	c         *Client
+	container Container
+	inspect   *ContainerInspect
+	memory    *memoryStats
+}
+
+type memoryStats struct {
+	Usage float64 `json:"usage"`
+	Limit float64 `json:"limit"`
+}
+
+type condition func(env *conditionEnv) (any, error)
+
+type conditionParser struct {
+	tokens []string
+	pos    int
+}
+
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		r := rune(s[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("()", r):
+			tokens = append(tokens, s[i:i+1])
+			i++
+		case strings.ContainsRune("=!<>&|", r):
+			j := i + 1
+			if j < len(s) && strings.ContainsRune("=&|", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		case r == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, s[i:j+1])
+			i = j + 1
+		case r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", r, i)
+		}
+	}
+
+	return tokens, nil
+}
+
+func parseCondition(s string) (condition, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &conditionParser{tokens: tokens}
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+
+	return cond, nil
+}
+
+func (p *conditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+
+	return ""
+}
+
+func (p *conditionParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *conditionParser) or() (condition, error) {
+	left, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.next()
+		var right condition
+		if right, err = p.and(); err == nil {
+			left = logical(left, right, true)
+		}
+	}
+
+	return left, err
+}
+
+func (p *conditionParser) and() (condition, error) {
+	left, err := p.unary()
+	for err == nil && p.peek() == "&&" {
+		p.next()
+		var right condition
+		if right, err = p.unary(); err == nil {
+			left = logical(left, right, false)
+		}
+	}
+
+	return left, err
+}
+
+func (p *conditionParser) unary() (condition, error) {
+	if p.peek() != "!" {
+		return p.comparison()
+	}
+	p.next()
+
+	operand, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(env *conditionEnv) (any, error) {
+		v, err := operand(env)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("! needs a boolean, got %v", v)
+		}
+		return !b, nil
+	}, nil
+}
+
+func (p *conditionParser) comparison() (condition, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(env *conditionEnv) (any, error) {
+		a, err := left(env)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right(env)
+		if err != nil {
+			return nil, err
+		}
+		return compare(a, op, b)
+	}, nil
+}
+
+func (p *conditionParser) primary() (condition, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of condition")
+	case t == "(":
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return inner, nil
+	case t == "true" || t == "false":
+		v := t == "true"
+		return func(*conditionEnv) (any, error) { return v, nil }, nil
+	case strings.HasPrefix(t, `"`):
+		v, err := strconv.Unquote(t)
+		if err != nil {
+			return nil, err
+		}
+		return func(*conditionEnv) (any, error) { return v, nil }, nil
+	case unicode.IsDigit(rune(t[0])):
+		v, err := parseQuantity(t)
+		if err != nil {
+			return nil, err
+		}
+		return func(*conditionEnv) (any, error) { return v, nil }, nil
+	case t == "label":
+		if p.next() != "(" {
+			return nil, fmt.Errorf("label needs a quoted key")
+		}
+		key, err := strconv.Unquote(p.next())
+		if err != nil || p.next() != ")" {
+			return nil, fmt.Errorf("label needs a quoted key")
+		}
+		return func(env *conditionEnv) (any, error) { return env.container.Labels[key], nil }, nil
+	}
+
+	return func(env *conditionEnv) (any, error) { return env.lookup(t) }, nil
+}
+
+func parseQuantity(t string) (float64, error) {
+	i := strings.IndexFunc(t, unicode.IsLetter)
+	if i < 0 {
+		return strconv.ParseFloat(t, 64)
+	}
+
+	unit, ok := conditionUnits[t[i:]]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit in %q", t)
+	}
+	v, err := strconv.ParseFloat(t[:i], 64)
+
+	return v * unit, err
+}
+
+func logical(left condition, right condition, or bool) condition {
+	return func(env *conditionEnv) (any, error) {
+		for _, operand := range []condition{left, right} {
+			v, err := operand(env)
+			if err != nil {
+				return nil, err
+			}
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("&& and || need booleans, got %v", v)
+			}
+			if b == or {
+				return or, nil
+			}
+		}
+		return !or, nil
+	}
+}
+
+func compare(a any, op string, b any) (bool, error) {
+	if x, ok := a.(float64); ok {
+		y, ok := b.(float64)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %v with %v", a, b)
+		}
+		switch op {
+		case "<":
+			return x < y, nil
+		case "<=":
+			return x <= y, nil
+		case ">":
+			return x > y, nil
+		case ">=":
+			return x >= y, nil
+		}
+	}
+
+	switch op {
+	case "==":
+		return a == b, nil
+	case "!=":
+		return a != b, nil
+	}
+
+	return false, fmt.Errorf("%s needs numbers, got %v and %v", op, a, b)
+}
+
+func (env *conditionEnv) lookup(name string) (any, error) {
+	switch name {
+	case "name":
+		return strings.TrimPrefix(env.container.Name(), "/"), nil
+	case "image":
+		return env.container.Image, nil
+	case "memory", "memory_limit":
+		if env.memory == nil {
+			body, err := env.c.docker.stats(env.container.Id)
+			if err != nil {
+				return nil, err
+			}
+			var stats struct {
+				MemoryStats memoryStats `json:"memory_stats"`
+			}
+			if err := json.Unmarshal(body, &stats); err != nil {
+				return nil, err
+			}
+			env.memory = &stats.MemoryStats
+		}
+		if name == "memory" {
+			return env.memory.Usage, nil
+		}
+		return env.memory.Limit, nil
+	}
+
+	if env.inspect == nil {
+		inspect, err := env.c.inspectContainer(env.container.Id)
+		if err != nil {
+			return nil, err
+		}
+		env.inspect = inspect
+	}
+
+	switch name {
+	case "health":
+		return env.inspect.HealthStatus(), nil
+	case "state":
+		return env.inspect.State.Status, nil
+	case "exit_code":
+		return float64(env.inspect.State.ExitCode), nil
+	case "restart_count":
+		return float64(env.inspect.RestartCount), nil
+	case "failing_streak":
+		if env.inspect.State.Health == nil {
+			return float64(0), nil
+		}
+		return float64(env.inspect.State.Health.FailingStreak), nil
+	case "uptime":
+		started, err := time.Parse(time.RFC3339Nano, env.inspect.State.StartedAt)
+		if err != nil || !env.inspect.State.Running {
+			return float64(0), nil
+		}
+		return time.Since(started).Seconds(), nil
+	}
+
+	return nil, fmt.Errorf("unknown identifier %q", name)
+}
+
+func (c *Client) conditionOf(container Container) string {
+	if cond := container.Labels["autoheal.condition"]; cond != "" {
+		return cond
+	}
+
+	return c.cfg.Condition
+}
+
+func (c *Client) evaluateCondition(container Container) (bool, error) {
+	text := c.conditionOf(container)
+
+	c.mu.Lock()
+	cond, ok := c.conditions[text]
+	c.mu.Unlock()
+	if !ok {
+		var err error
+		if cond, err = parseCondition(text); err != nil {
+			return false, fmt.Errorf("invalid condition %q: %w", text, err)
+		}
+		c.mu.Lock()
+		c.conditions[text] = cond
+		c.mu.Unlock()
+	}
+
+	v, err := cond(&conditionEnv{c: c, container: container})
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition %q is not a boolean", text)
+	}
+
+	return b, nil
+}
+
+func (c *Client) scanConditions(f *labelFilter) []candidate {
+	qs := map[string][]string{}
+	if c.cfg.Condition == "" {
+		qs["label"] = []string{"autoheal.condition"}
+	}
+	containers, err := c.listFiltered(f, qs)
+	if err != nil {
+		errorf("Failed to list containers for condition checks. %s\n", err)
+		return nil
+	}
+
+	return scanEach(containers, func(container Container) *candidate {
+		if c.hostShuttingDown() || container.Name() == "" || container.State == RESTARTING || disabled(container) {
+			return nil
+		}
+
+		t := time.Now().Format(TIME_FORMAT)
+		id := shortID(container.Id)
+
+		met, err := c.evaluateCondition(container)
+		if err != nil {
+			containerLog(container.Name(), id).errorf("%s Failed to evaluate the condition of container %s (%s). %s\n", t, container.Name(), id, err)
+			return nil
+		}
+		if !met {
+			return nil
+		}
+
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) met its restart condition %s.\n", t, container.Name(), id, c.scrub(container, "autoheal.condition", c.conditionOf(container)))
+		return &candidate{Container: container, Reason: "met its restart condition"}
+	})
+}
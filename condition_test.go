@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionHitsAreGated(t *testing.T) {
+	c := newTestClient(t, map[string]string{
+		"METRICS_ENABLED":       "false",
+		"AUTOHEAL_MAX_ATTEMPTS": "1",
+	})
+
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}
+	hit := candidate{Container: container, Reason: "met its restart condition"}
+	budget := -1
+
+	if _, ok := c.admit(hit.Container, hit.Reason, map[string]int{}, &budget, shortID(container.Id), ""); !ok {
+		t.Fatal("condition hit without restart history was not admitted")
+	}
+
+	c.history[container.Id] = &restartHistory{Restarts: []time.Time{time.Now().Add(-time.Minute)}}
+	if _, ok := c.admit(hit.Container, hit.Reason, map[string]int{}, &budget, shortID(container.Id), ""); ok {
+		t.Error("condition hit bypassed AUTOHEAL_MAX_ATTEMPTS")
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	tests := []struct {
+		cond string
+		err  string
+	}{
+		{"", "unexpected end"},
+		{"health ==", "unexpected end"},
+		{"(1 < 2", "missing )"},
+		{"1 < 2 )", `unexpected ")"`},
+		{"1 < 2 3", `unexpected "3"`},
+		{`name == "web`, "unterminated string"},
+		{"name # 1", "unexpected '#'"},
+		{"memory > 1Xi", "unknown unit"},
+		{"label(tier) == 1", "quoted key"},
+		{`label("tier" == 1`, "quoted key"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseCondition(tt.cond)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseCondition(%q) error = %v, want %q", tt.cond, err, tt.err)
+		}
+	}
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	inspect := &ContainerInspect{RestartCount: 3}
+	inspect.State.Status = "running"
+	inspect.State.Running = true
+	inspect.State.ExitCode = 137
+	inspect.State.StartedAt = time.Now().Add(-2 * time.Hour).Format(time.RFC3339Nano)
+	inspect.State.Health = &ContainerHealth{Status: "unhealthy", FailingStreak: 4}
+
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}, Image: "nginx:1.25", Labels: map[string]string{"tier": "db"}}
+
+	tests := []struct {
+		cond string
+		want any
+		err  string
+	}{
+		{"true || false && false", true, ""},
+		{"(true || false) && false", false, ""},
+		{"false && bogus", false, ""},
+		{"true || bogus", true, ""},
+		{"!false", true, ""},
+		{"!!true", true, ""},
+		{"!(1 < 2) || false", false, ""},
+		{"1Ki == 1024 && 1Mi == 1048576 && 1Gi == 1073741824", true, ""},
+		{"2m == 120 && 1h == 3600 && 1d == 86400 && 30s == 30", true, ""},
+		{"0.5Gi == 512Mi", true, ""},
+		{"memory > 256Mi && memory < memory_limit", true, ""},
+		{"uptime > 1h && uptime < 3h", true, ""},
+		{`health == "unhealthy" && failing_streak >= 3`, true, ""},
+		{`state == "running" && exit_code == 137 && restart_count != 2`, true, ""},
+		{`name == "web" && image != "redis"`, true, ""},
+		{`label("tier") == "db" && label("missing") == ""`, true, ""},
+		{`"a\"b" == "a\"b"`, true, ""},
+		{"1 <= 1 && 1 >= 1 && !(1 > 1)", true, ""},
+		{"1 == 1 == true", nil, `unexpected "=="`},
+		{"bogus == 1", nil, `unknown identifier "bogus"`},
+		{`memory > "big"`, nil, "cannot compare"},
+		{`name < "x"`, nil, "needs numbers"},
+		{"1 && true", nil, "need booleans"},
+		{"!1", nil, "needs a boolean"},
+		{"memory", nil, "not a boolean"},
+	}
+
+	for _, tt := range tests {
+		d := &fakeAPI{inspects: map[string]*ContainerInspect{container.Id: inspect}, memory: memoryStats{Usage: 512 << 20, Limit: 1 << 30}}
+		c := newFakeClient(t, d, map[string]string{})
+		container.Labels["autoheal.condition"] = tt.cond
+
+		got, err := c.evaluateCondition(container)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want %q", tt.cond, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.cond, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+//go:embed config.schema.json
+var configSchema []byte
+
+type schema struct {
+	Type                 string             `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Minimum              *float64           `json:"minimum"`
+	Enum                 []any              `json:"enum"`
+	Env                  string             `json:"x-env"`
+}
+
+var fileValues atomic.Pointer[map[string]string]
+
+func jsonType(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if f, err := n.Float64(); err == nil && f == math.Trunc(f) && !strings.ContainsAny(n.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+func (s *schema) validate(path string, v any) []string {
+	var errs []string
+
+	got := jsonType(v)
+	if s.Type != "" && got != s.Type && !(s.Type == "number" && got == "integer") {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, s.Type, got)}
+	}
+
+	if s.Minimum != nil {
+		if n, ok := v.(json.Number); ok {
+			if f, _ := n.Float64(); f < *s.Minimum {
+				errs = append(errs, fmt.Sprintf("%s: must be at least %v, got %s", path, *s.Minimum, n))
+			}
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: must be one of %v, got %v", path, s.Enum, v))
+		}
+	}
+
+	if obj, ok := v.(map[string]any); ok {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			field := strings.TrimPrefix(path+"."+k, ".")
+			prop, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					errs = append(errs, fmt.Sprintf("%s: unknown field", field))
+				}
+				continue
+			}
+			errs = append(errs, prop.validate(field, obj[k])...)
+		}
+	}
+
+	return errs
+}
+
+func loadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s schema
+	if err := json.Unmarshal(configSchema, &s); err != nil {
+		return nil, fmt.Errorf("invalid embedded config schema: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if errs := s.validate("", doc); len(errs) > 0 {
+		return nil, fmt.Errorf("%s is invalid:\n  %s", path, strings.Join(errs, "\n  "))
+	}
+
+	values := map[string]string{}
+	for k, v := range doc.(map[string]any) {
+		values[s.Properties[k].Env] = fmt.Sprint(v)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+const REDACTED = "<redacted>"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func plain(v reflect.Value) any {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		out := map[string]any{}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				out[v.Type().Field(i).Name] = plain(v.Field(i))
+			}
+		}
+		return out
+	case reflect.Map:
+		out := map[string]any{}
+		for _, k := range v.MapKeys() {
+			out[k.String()] = plain(v.MapIndex(k))
+		}
+		return out
+	}
+
+	return v.Interface()
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return REDACTED
+	}
+
+	return u.Redacted()
+}
+
+func (c *Client) effectiveConfig() map[string]any {
+	cfg := *c.cfg
+	if f := c.filter.Load(); f != nil {
+		cfg.ContainerLabel = f.Label
+	}
+	if cfg.WebHookUrl != "" {
+		cfg.WebHookUrl = REDACTED
+	}
+	if cfg.WebHookHeaders != "" {
+		cfg.WebHookHeaders = REDACTED
+	}
+	if cfg.WebHookSecret != "" {
+		cfg.WebHookSecret = REDACTED
+	}
+	if cfg.WebHookKey != "" {
+		cfg.WebHookKey = REDACTED
+	}
+	if cfg.EventBrokerUrl != "" {
+		cfg.EventBrokerUrl = redactURL(cfg.EventBrokerUrl)
+	}
+	if cfg.FlagUrl != "" {
+		cfg.FlagUrl = redactURL(cfg.FlagUrl)
+	}
+	if cfg.ControlToken != "" {
+		cfg.ControlToken = REDACTED
+	}
+	if cfg.MetricsPass != "" {
+		cfg.MetricsPass = REDACTED
+	}
+	if cfg.RegistryAuth != "" {
+		cfg.RegistryAuth = REDACTED
+	}
+
+	return plain(reflect.ValueOf(cfg)).(map[string]any)
+}
+
+func (c *Client) authorized(r *http.Request) bool {
+	if c.cfg.ControlToken == "" {
+		return false
+	}
+
+	expected := "Bearer " + c.cfg.ControlToken
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) == 1
+}
+
+func (c *Client) controlHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !c.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (c *Client) metricsAuth(next http.Handler) http.Handler {
+	if c.cfg.MetricsUser == "" || c.cfg.MetricsPass == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(c.cfg.MetricsUser))
+		passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(c.cfg.MetricsPass))
+		if userOK&passOK != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Client) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", CONTENT_TYPE)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(c.effectiveConfig())
+}
+
+type cycleSummary struct {
+	Found     int      `json:"found"`
+	Restarted []string `json:"restarted"`
+	Failed    []string `json:"failed"`
+}
+
+func (c *Client) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.ready.Load() {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+		return
+	}
+
+	summary, err := c.runOnce(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", CONTENT_TYPE)
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		user, pass string
+		auth       bool
+		sentUser   string
+		sentPass   string
+		status     int
+	}{
+		{"no credentials configured", "", "", false, "", "", http.StatusOK},
+		{"authorized", "prom", "s3cret", true, "prom", "s3cret", http.StatusOK},
+		{"missing", "prom", "s3cret", false, "", "", http.StatusUnauthorized},
+		{"wrong password", "prom", "s3cret", true, "prom", "guess", http.StatusUnauthorized},
+		{"wrong user", "prom", "s3cret", true, "admin", "s3cret", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		c := newTestClient(t, map[string]string{"METRICS_BASIC_AUTH_USER": tt.user, "METRICS_BASIC_AUTH_PASS": tt.pass})
+
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.auth {
+			r.SetBasicAuth(tt.sentUser, tt.sentPass)
+		}
+		w := httptest.NewRecorder()
+		c.metricsAuth(ok).ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate challenge", tt.name)
+		}
+	}
+}
+
+func TestMetricsPath(t *testing.T) {
+	for _, path := range []string{"/metrics", "/internal/prometheus"} {
+		c := newTestClient(t, map[string]string{"METRICS_PATH": path})
+		mux := c.metricsMux()
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("METRICS_PATH=%s: %s answered %d", path, path, w.Code)
+		}
+
+		if path != "/metrics" {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("METRICS_PATH=%s: /metrics answered %d, want 404", path, w.Code)
+			}
+		}
+	}
+}
+
+func TestControlEndpoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		auth   string
+		ready  bool
+		method string
+		path   string
+		status int
+	}{
+		{"config without token", "", "", true, http.MethodGet, "/config", http.StatusNotFound},
+		{"scan without token", "", "", true, http.MethodPost, "/scan", http.StatusNotFound},
+		{"config unauthorized", "t0ken", "", true, http.MethodGet, "/config", http.StatusUnauthorized},
+		{"config wrong token", "t0ken", "Bearer guess", true, http.MethodGet, "/config", http.StatusUnauthorized},
+		{"config", "t0ken", "Bearer t0ken", true, http.MethodGet, "/config", http.StatusOK},
+		{"scan before ready", "t0ken", "Bearer t0ken", false, http.MethodPost, "/scan", http.StatusServiceUnavailable},
+		{"scan", "t0ken", "Bearer t0ken", true, http.MethodPost, "/scan", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		c := newFakeClient(t, &fakeAPI{}, map[string]string{"CONTROL_TOKEN": tt.token})
+		c.ready.Store(tt.ready)
+
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		c.metricsMux().ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
+
+func TestEffectiveConfigRedactsSecrets(t *testing.T) {
+	c := newFakeClient(t, &fakeAPI{}, map[string]string{
+		"WEBHOOK_URL":            "https://hooks.example.com/T000/B000/XXXX",
+		"WEBHOOK_KEY":            "xoxb-secret",
+		"WEBHOOK_SIGNING_SECRET": "s3cret",
+		"CONTROL_TOKEN":          "t0ken",
+		"REGISTRY_AUTH":          `{"username": "ci", "password": "s3cret"}`,
+	})
+
+	cfg := c.effectiveConfig()
+	for _, key := range []string{"WebHookUrl", "WebHookKey", "WebHookSecret", "ControlToken", "RegistryAuth"} {
+		if cfg[key] != REDACTED {
+			t.Errorf("%s = %v, want it redacted", key, cfg[key])
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (c *Client) ping() error {
+	response, err := c.httpd.Get(c.cfg.DockerUrl + "/_ping")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("ping returned status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// daemonInfo returns the ID the daemon reports in /info. The API exposes no
+// start time, so a changed ID is what tells a replaced daemon from a blip.
+func (c *Client) daemonInfo() (string, error) {
+	body, err := c.dockerCall(http.MethodGet, c.cfg.DockerUrl+"/info", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var info struct {
+		ID string `json:"ID"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return "", err
+	}
+
+	return info.ID, nil
+}
+
+func (c *Client) daemonTarget() string {
+	switch {
+	case strings.HasPrefix(c.cfg.DockerHost, "ssh://"):
+		return "SSH host " + c.cfg.DockerHost
+	case strings.HasPrefix(c.cfg.DockerHost, "tcp://"):
+		return "TCP host " + c.cfg.DockerUrl
+	}
+
+	return "unix socket " + c.cfg.DockerSocks
+}
+
+func (c *Client) waitForDaemon() {
+	deadline := time.Now().Add(c.cfg.StartPeriod)
+	backoff := time.Second
+
+	for {
+		err := c.ping()
+		if err == nil {
+			c.daemonID, _ = c.daemonInfo()
+			infof("%s Connected to Docker daemon at %s\n", time.Now().Format(TIME_FORMAT), c.daemonTarget())
+			return
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			warnf("%s Docker daemon at %s is unreachable - continuing anyway. %s\n", time.Now().Format(TIME_FORMAT), c.daemonTarget(), err)
+			return
+		}
+		if backoff < wait {
+			wait = backoff
+		}
+		warnf("%s Docker daemon at %s is unreachable, retrying in %s. %s\n", time.Now().Format(TIME_FORMAT), c.daemonTarget(), wait.Round(time.Second), err)
+
+		select {
+		case <-time.After(wait):
+		case <-c.ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+func (c *Client) daemonStable(containers []Container, err error) bool {
+	t := time.Now().Format(TIME_FORMAT)
+
+	if err == nil && len(containers) == 0 && c.lastListed > 0 {
+		err = c.ping()
+	}
+
+	if err != nil {
+		if !c.daemonDown {
+			c.daemonDown = true
+			warnf("%s Docker daemon looks unavailable - keeping container state until it is back.\n", t)
+			if transport, ok := c.httpd.Transport.(*http.Transport); ok {
+				transport.CloseIdleConnections()
+			}
+		}
+		return false
+	}
+
+	c.lastListed = len(containers)
+	if c.daemonDown {
+		c.daemonDown = false
+		id, err := c.daemonInfo()
+		if err == nil && c.daemonID != "" && id != c.daemonID {
+			infof("%s Docker daemon was restarted - resuming.\n", t)
+			if c.cfg.MetricsEnabled {
+				c.daemonRestarts.Add(c.ctx, 1)
+			}
+		} else {
+			infof("%s Docker daemon is back - resuming.\n", t)
+		}
+		if err == nil {
+			c.daemonID = id
+		}
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDaemonRestartNeedsNewID(t *testing.T) {
+	var id atomic.Value
+	id.Store("A")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/info" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"ID": %q}`, id.Load())
+	}))
+	defer srv.Close()
+
+	out := captureLog(t)
+	c := newTestClient(t, map[string]string{
+		"DOCKER_HOST":     "tcp://" + strings.TrimPrefix(srv.URL, "http://"),
+		"METRICS_ENABLED": "false",
+	})
+	c.daemonID, _ = c.daemonInfo()
+
+	containers := []Container{{Id: "0123456789abcdef", Names: []string{"/web"}}}
+	outage := func() {
+		if c.daemonStable(nil, errors.New("connection reset by peer")) {
+			t.Fatal("a failed listing was treated as stable")
+		}
+		if c.daemonStable(containers, nil) {
+			t.Fatal("the first listing after an outage was treated as stable")
+		}
+	}
+
+	outage()
+	if strings.Contains(out.String(), "was restarted") {
+		t.Errorf("a transient list error was counted as a daemon restart:\n%s", out)
+	}
+
+	id.Store("B")
+	outage()
+	if n := strings.Count(out.String(), "was restarted"); n != 1 {
+		t.Errorf("logged %d daemon restarts after the ID changed, want 1:\n%s", n, out)
+	}
+	if c.daemonID != "B" {
+		t.Errorf("daemon ID = %q, want B", c.daemonID)
+	}
+}
+
+func TestWaitForDaemon(t *testing.T) {
+	var pings atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_ping" {
+			http.NotFound(w, r)
+			return
+		}
+		if pings.Add(1) <= 2 {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	defer srv.Close()
+
+	out := captureLog(t)
+	c := newTestClient(t, map[string]string{
+		"DOCKER_HOST":           "tcp://" + strings.TrimPrefix(srv.URL, "http://"),
+		"METRICS_ENABLED":       "false",
+		"AUTOHEAL_START_PERIOD": "30",
+	})
+	c.waitForDaemon()
+
+	if n := pings.Load(); n != 3 {
+		t.Errorf("pinged %d times, want 3", n)
+	}
+	if n := strings.Count(out.String(), "is unreachable, retrying"); n != 2 {
+		t.Errorf("logged %d retries, want 2:\n%s", n, out)
+	}
+	if !strings.Contains(out.String(), "Connected to Docker daemon at TCP host") {
+		t.Errorf("log does not say a TCP host was connected:\n%s", out)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func (c *Client) dependencyUp(container Container, id string, t string) bool {
+	url := container.Labels["autoheal.depcheck.url"]
+	if url == "" {
+		return true
+	}
+
+	err := c.probe(url)
+
+	c.mu.Lock()
+	s := c.stateFor(container.Id)
+	alert := err != nil && !s.DependencyAlerted
+	s.DependencyAlerted = err != nil
+	c.mu.Unlock()
+
+	if err == nil {
+		return true
+	}
+
+	message := c.scrub(container, "autoheal.depcheck.url", fmt.Sprintf("Dependency %s is down (%s)", url, err))
+	containerLog(container.Name(), id).infof("%s Container %s (%s) found to be unhealthy - %s, deferring restart.\n", t, container.Name(), id, message)
+	if c.cfg.MetricsEnabled {
+		c.deferred.Add(c.ctx, 1, attribute.String("reason", "dependency"))
+	}
+	c.statsd.Count("restarts_deferred", map[string]string{"container": container.Name(), "reason": "dependency"})
+
+	if alert {
+		e := Event{Time: t, Container: container.Name(), Id: id, Result: RESULT_DEFERRED, Message: message + ", deferring the restart until it recovers"}
+		if err := c.notify(e); err != nil {
+			errorf("Failed to call webhook. %s\n", err)
+		}
+	}
+
+	return false
+}
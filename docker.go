@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ContainerHealth struct {
+	Status        string `json:"Status"`
+	FailingStreak int    `json:"FailingStreak"`
+}
+
+type ContainerInspect struct {
+	Id    string `json:"Id"`
+	Name  string `json:"Name"`
+	Image string `json:"Image"`
+	State struct {
+		Status     string           `json:"Status"`
+		Running    bool             `json:"Running"`
+		Restarting bool             `json:"Restarting"`
+		Health     *ContainerHealth `json:"Health"`
+		ExitCode   int              `json:"ExitCode"`
+		StartedAt  string           `json:"StartedAt"`
+	} `json:"State"`
+	Config struct {
+		Image        string              `json:"Image"`
+		Labels       map[string]string   `json:"Labels"`
+		ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+		Healthcheck  *struct {
+			Test []string `json:"Test"`
+		} `json:"Healthcheck"`
+	} `json:"Config"`
+	HostConfig struct {
+		RestartPolicy struct {
+			Name              string `json:"Name"`
+			MaximumRetryCount int    `json:"MaximumRetryCount"`
+		} `json:"RestartPolicy"`
+	} `json:"HostConfig"`
+	RestartCount    int `json:"RestartCount"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func (i *ContainerInspect) ManagedByDocker() bool {
+	switch i.HostConfig.RestartPolicy.Name {
+	case "always", "unless-stopped":
+		return i.State.Restarting || !i.State.Running
+	}
+
+	return false
+}
+
+func (i *ContainerInspect) HasHealthcheck() bool {
+	hc := i.Config.Healthcheck
+	return hc != nil && len(hc.Test) > 0 && hc.Test[0] != "NONE"
+}
+
+func (i *ContainerInspect) HealthStatus() string {
+	if i.State.Health == nil {
+		return ""
+	}
+
+	return i.State.Health.Status
+}
+
+func newSemaphore(size int) chan struct{} {
+	if size <= 0 {
+		return nil
+	}
+
+	return make(chan struct{}, size)
+}
+
+func (c *Client) inspectRaw(id string) ([]byte, error) {
+	if c.inspects != nil {
+		c.inspects <- struct{}{}
+		defer func() { <-c.inspects }()
+	}
+
+	start := time.Now()
+	body, err := c.dockerDo(http.MethodGet, id+"/json", nil)
+	if c.cfg.MetricsEnabled && c.inspectLatency != nil {
+		c.inspectLatency.Record(c.ctx, time.Since(start).Seconds())
+	}
+
+	return body, err
+}
+
+func (c *Client) inspectContainer(id string) (*ContainerInspect, error) {
+	body, err := c.docker.inspect(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var inspect ContainerInspect
+	if err := json.Unmarshal(body, &inspect); err != nil {
+		return nil, err
+	}
+
+	return &inspect, nil
+}
+
+func (c *Client) stillUnhealthy(id string) (bool, error) {
+	inspect, err := c.inspectContainer(id)
+	if err != nil {
+		return false, err
+	}
+
+	return c.filter.Load().Monitors(inspect), nil
+}
+
+func (c *Client) warnUnmonitored(f *labelFilter) {
+	if f.All() {
+		return
+	}
+
+	containers, err := c.listFiltered(f, map[string][]string{})
+	if err != nil {
+		return
+	}
+
+	current := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		current[container.Id] = true
+		if _, checked := c.hc[container.Id]; checked {
+			continue
+		}
+
+		inspect, err := c.inspectContainer(container.Id)
+		if err != nil {
+			continue
+		}
+
+		c.hc[container.Id] = inspect.HasHealthcheck()
+		if !c.hc[container.Id] {
+			containerLog(inspect.Name, shortID(container.Id)).warnf("%s Container %s (%s) matches label %s but has no healthcheck - it can't be monitored by health, consider adding a HEALTHCHECK or an autoheal.probe.url label.\n", time.Now().Format(TIME_FORMAT), inspect.Name, shortID(container.Id), f.Label)
+		}
+	}
+
+	for id := range c.hc {
+		if !current[id] {
+			delete(c.hc, id)
+		}
+	}
+}
+
+func (c *Client) dockerDo(method string, path string, payload any) ([]byte, error) {
+	return c.dockerCall(method, c.cfg.BaseUrl+path, payload)
+}
+
+func (c *Client) dockerCall(method string, target string, payload any) ([]byte, error) {
+	request, err := dockerRequest(method, target, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.dockerSend(&c.httpd, request)
+}
+
+func dockerRequest(method string, target string, payload any) (*http.Request, error) {
+	var reader io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	request, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		request.Header.Set("Content-Type", CONTENT_TYPE)
+	}
+
+	return request, nil
+}
+
+func (c *Client) dockerSend(client *http.Client, request *http.Request) ([]byte, error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotModified {
+		return body, nil
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, dockerError(request.URL.Path, response.StatusCode, body)
+	}
+
+	return body, nil
+}
+
+func (c *Client) dockerPost(path string) error {
+	_, err := c.dockerDo(http.MethodPost, path, nil)
+	return err
+}
+
+func (c *Client) killRestartContainer(id string, signal string, after string) error {
+	grace, err := strconv.Atoi(after)
+	if err != nil {
+		grace, err = strconv.Atoi(c.cfg.DefaultStopTimeout)
+		if err != nil {
+			grace = 10
+		}
+	}
+
+	if err := c.docker.signal(id, signal); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(time.Duration(grace) * time.Second)
+	for time.Now().Before(deadline) {
+		inspect, err := c.inspectContainer(id)
+		if err != nil {
+			return err
+		}
+		if !inspect.State.Running {
+			break
+		}
+
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		}
+	}
+
+	if err := c.docker.stop(id, "0"); err != nil {
+		return err
+	}
+
+	return c.docker.start(id)
+}
+
+func daemonMessage(body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+
+	return e.Message
+}
+
+func dockerError(path string, status int, body []byte) error {
+	if message := daemonMessage(body); message != "" {
+		return fmt.Errorf("%s returned status %d: %s", path, status, message)
+	}
+	if len(body) > 512 {
+		body = body[:512]
+	}
+
+	return fmt.Errorf("%s returned status %d: %s", path, status, bytes.TrimSpace(body))
+}
+
+func shortID(id string) string {
+	if r := []rune(id); len(r) > 12 {
+		return string(r[:12])
+	}
+
+	return id
+}
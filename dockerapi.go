@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type dockerAPI interface {
+	listUnhealthy(ctx context.Context, f *labelFilter) ([]Container, error)
+	list(ctx context.Context, query string) ([]Container, error)
+	inspect(id string) ([]byte, error)
+	restart(ctx context.Context, id string, timeout string, signal string) error
+	kill(id string) error
+	signal(id string, signal string) error
+	stop(id string, timeout string) error
+	start(id string) error
+	recreate(id string, image string) (string, error)
+	logs(id string, query string) (string, error)
+	stats(id string) ([]byte, error)
+}
+
+type daemonAPI struct {
+	c *Client
+}
+
+func (d daemonAPI) listUnhealthy(ctx context.Context, f *labelFilter) ([]Container, error) {
+	return d.c.queryFiltered(ctx, f)
+}
+
+func (d daemonAPI) list(ctx context.Context, query string) ([]Container, error) {
+	return d.c.queryContainers(ctx, query)
+}
+
+func (d daemonAPI) inspect(id string) ([]byte, error) {
+	return d.c.inspectRaw(id)
+}
+
+func (d daemonAPI) restart(ctx context.Context, id string, timeout string, signal string) error {
+	return d.c.restartContainer(ctx, id, timeout, signal)
+}
+
+func (d daemonAPI) kill(id string) error {
+	return d.c.killContainer(id)
+}
+
+func (d daemonAPI) signal(id string, signal string) error {
+	return d.c.dockerPost(id + "/kill?signal=" + url.QueryEscape(signal))
+}
+
+func (d daemonAPI) stop(id string, timeout string) error {
+	return d.c.dockerPost(id + "/stop?t=" + url.QueryEscape(timeout))
+}
+
+func (d daemonAPI) start(id string) error {
+	return d.c.dockerPost(id + "/start")
+}
+
+func (d daemonAPI) recreate(id string, image string) (string, error) {
+	return d.c.recreateContainer(id, image)
+}
+
+func (d daemonAPI) logs(id string, query string) (string, error) {
+	return d.c.containerLogs(id, query)
+}
+
+func (d daemonAPI) stats(id string) ([]byte, error) {
+	return d.c.dockerDo(http.MethodGet, id+"/stats?stream=false&one-shot=true", nil)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDaemonAPI(t *testing.T) {
+	const id = "0123456789abcdef"
+	tests := []struct {
+		name string
+		call func(d dockerAPI) error
+		want []string
+	}{
+		{"restart", func(d dockerAPI) error { return d.restart(context.Background(), id, "", "") }, []string{"POST /containers/" + id + "/restart t=10"}},
+		{"restart with timeout", func(d dockerAPI) error { return d.restart(context.Background(), id, "30", "") }, []string{"POST /containers/" + id + "/restart t=30"}},
+		{"kill", func(d dockerAPI) error { return d.kill(id) }, []string{"POST /containers/" + id + "/kill ", "POST /containers/" + id + "/start "}},
+		{"signal", func(d dockerAPI) error { return d.signal(id, "SIGHUP") }, []string{"POST /containers/" + id + "/kill signal=SIGHUP"}},
+		{"stop", func(d dockerAPI) error { return d.stop(id, "0") }, []string{"POST /containers/" + id + "/stop t=0"}},
+		{"start", func(d dockerAPI) error { return d.start(id) }, []string{"POST /containers/" + id + "/start "}},
+		{"inspect", func(d dockerAPI) error { _, err := d.inspect(id); return err }, []string{"GET /containers/" + id + "/json "}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				got = append(got, r.Method+" "+r.URL.Path+" "+r.URL.RawQuery)
+				mu.Unlock()
+				if r.Method == http.MethodGet {
+					w.Write([]byte(`{"Id": "` + id + `"}`))
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			c := newTestClient(t, map[string]string{
+				"DOCKER_HOST":     "tcp://" + strings.TrimPrefix(srv.URL, "http://"),
+				"METRICS_ENABLED": "false",
+			})
+			if err := tt.call(daemonAPI{c}); err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("requests = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActCallsDockerAPI(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		err    error
+		call   string
+		result string
+	}{
+		{"restart", ACTION_RESTART, nil, "restart", RESULT_SUCCESS},
+		{"kill", ACTION_KILL, nil, "kill", RESULT_SUCCESS},
+		{"recreate", ACTION_RECREATE, nil, "recreate", RESULT_SUCCESS},
+		{"restart fails", ACTION_RESTART, errors.New("conflict"), "restart", RESULT_FAILURE},
+		{"kill fails", ACTION_KILL, errors.New("conflict"), "kill", RESULT_FAILURE},
+		{"recreate fails", ACTION_RECREATE, errors.New("conflict"), "recreate", RESULT_FAILURE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeAPI{err: tt.err}
+			c := newFakeClient(t, d, map[string]string{})
+
+			container := Container{Id: "0123456789abcdef", Names: []string{"/web"}}
+			if got := c.act(c.ctx, container, shortID(container.Id), "", REASON_UNHEALTHY, tt.action); got != tt.result {
+				t.Errorf("act() = %q, want %q", got, tt.result)
+			}
+			if len(d.calls) != 1 || d.calls[0] != tt.call+" "+container.Id {
+				t.Errorf("docker calls = %v, want [%s %s]", d.calls, tt.call, container.Id)
+			}
+		})
+	}
+}
+
+func TestRunOnceRestartsUnhealthy(t *testing.T) {
+	tests := []struct {
+		name      string
+		unhealthy []Container
+		restarts  int
+	}{
+		{"none", nil, 0},
+		{"one", []Container{{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}}, 1},
+		{"restarting", []Container{{Id: "0123456789abcdef", Names: []string{"/web"}, State: RESTARTING}}, 0},
+		{"no name", []Container{{Id: "0123456789abcdef", State: "running"}}, 0},
+		{"two", []Container{
+			{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"},
+			{Id: "fedcba9876543210", Names: []string{"/db"}, State: "running"},
+		}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeAPI{unhealthy: tt.unhealthy}
+			c := newFakeClient(t, d, map[string]string{})
+
+			summary, err := c.runOnce(c.ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := d.count("restart"); n != tt.restarts {
+				t.Errorf("restarted %d container(s), want %d", n, tt.restarts)
+			}
+			if len(summary.Restarted) != tt.restarts {
+				t.Errorf("summary lists %d restart(s), want %d", len(summary.Restarted), tt.restarts)
+			}
+		})
+	}
+}
+
+func TestStopSignalLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		query  string
+	}{
+		{"no labels", nil, "t=10"},
+		{"signal", map[string]string{"autoheal.stop.signal": "SIGUSR1"}, "t=10&signal=SIGUSR1"},
+		{"signal and timeout", map[string]string{"autoheal.stop.signal": "SIGINT", "autoheal.stop.timeout": "30"}, "t=30&signal=SIGINT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := make(chan string, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/restart") {
+					queries <- r.URL.RawQuery
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			c := newTestClient(t, map[string]string{
+				"DOCKER_HOST":     "tcp://" + strings.TrimPrefix(srv.URL, "http://"),
+				"METRICS_ENABLED": "false",
+			})
+			container := Container{Id: "0123456789abcdef", Names: []string{"/web"}, Labels: tt.labels}
+			if err := c.retryRestart(c.ctx, container, shortID(container.Id)); err != nil {
+				t.Fatal(err)
+			}
+			if got := <-queries; got != tt.query {
+				t.Errorf("restart query = %q, want %q", got, tt.query)
+			}
+		})
+	}
+}
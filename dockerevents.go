@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	SOURCE_AUTOHEAL = "autoheal"
+	SOURCE_EXTERNAL = "external"
+)
+
+type dockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+func (c *Client) expectStart(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expected[id] = time.Now()
+}
+
+func (c *Client) startSource(id string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	window := c.httpd.Timeout + c.cfg.RestartTimeoutBuffer + time.Minute
+	for k, at := range c.expected {
+		if time.Since(at) > window {
+			delete(c.expected, k)
+		}
+	}
+
+	if _, ok := c.expected[id]; ok {
+		delete(c.expected, id)
+		return SOURCE_AUTOHEAL
+	}
+
+	return SOURCE_EXTERNAL
+}
+
+func (c *Client) streamEvents(events []string, up *atomic.Bool, handle func(dockerEvent)) error {
+	filters, err := json.Marshal(map[string][]string{"type": []string{"container"}, "event": events})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.cfg.DockerUrl+"/events?filters="+url.QueryEscape(string(filters)), nil)
+	if err != nil {
+		return err
+	}
+
+	client := c.httpd
+	client.Timeout = 0
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("events returned status %d", response.StatusCode)
+	}
+	if up != nil {
+		up.Store(true)
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var e dockerEvent
+		if err := decoder.Decode(&e); err != nil {
+			return err
+		}
+		if e.Type == "container" {
+			handle(e)
+		}
+	}
+}
+
+func (c *Client) watchStream(events []string, up *atomic.Bool, handle func(dockerEvent)) {
+	backoff := time.Second
+	for c.ctx.Err() == nil {
+		connected := time.Now()
+		err := c.streamEvents(events, up, handle)
+		if up != nil {
+			up.Store(false)
+		}
+		if c.ctx.Err() != nil {
+			return
+		}
+		if time.Since(connected) > time.Minute {
+			backoff = time.Second
+		}
+		warnf("Docker events stream interrupted, reconnecting in %s. %s\n", backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+func (c *Client) watchEvents() {
+	c.watchStream([]string{"start"}, nil, func(e dockerEvent) {
+		if e.Action != "start" {
+			return
+		}
+
+		c.starts.Add(c.ctx, 1,
+			attribute.String("container", "/"+e.Actor.Attributes["name"]),
+			attribute.String("source", c.startSource(e.Actor.ID)),
+		)
+	})
+}
+
+func (c *Client) triggerScan() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Client) watchUnhealthy() {
+	c.watchStream([]string{"health_status", "die"}, &c.eventsUp, func(e dockerEvent) {
+		switch e.Action {
+		case "health_status: unhealthy", "die":
+		default:
+			return
+		}
+
+		containerLog(e.Actor.Attributes["name"], shortID(e.Actor.ID)).debugf("%s Container /%s (%s) reported %s - Scanning now.\n", time.Now().Format(TIME_FORMAT), e.Actor.Attributes["name"], shortID(e.Actor.ID), e.Action)
+		c.triggerScan()
+		if c.cfg.Debounce > 0 {
+			time.AfterFunc(c.cfg.Debounce+time.Second, c.triggerScan)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const eventStream = `{"Type":"container","Action":"health_status: healthy","Actor":{"ID":"0123456789abcdef","Attributes":{"name":"web"}}}
+{"Type":"network","Action":"connect","Actor":{"ID":"fedcba9876543210","Attributes":{"name":"bridge"}}}
+{"Type":"container","Action":"health_status: unhealthy","Actor":{"ID":"0123456789abcdef","Attributes":{"name":"web"}}}
+`
+
+func newEventsClient(t *testing.T) (*Client, chan string) {
+	t.Helper()
+	filters := make(chan string, 10)
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events" {
+			http.NotFound(w, r)
+			return
+		}
+		filters <- r.URL.Query().Get("filters")
+		io.WriteString(w, eventStream)
+	}))
+	t.Cleanup(daemon.Close)
+
+	c := newTestClient(t, map[string]string{
+		"METRICS_ENABLED": "false",
+		"DOCKER_HOST":     "tcp://" + strings.TrimPrefix(daemon.URL, "http://"),
+	})
+
+	return c, filters
+}
+
+func TestStreamEvents(t *testing.T) {
+	c, filters := newEventsClient(t)
+
+	var actions []string
+	var up atomic.Bool
+	err := c.streamEvents([]string{"health_status", "die"}, &up, func(e dockerEvent) {
+		actions = append(actions, e.Action)
+	})
+	if err != io.EOF {
+		t.Errorf("streamEvents at the end of the stream returned %v, want EOF", err)
+	}
+
+	if !up.Load() {
+		t.Error("streamEvents did not mark the stream as connected")
+	}
+	if got := strings.Join(actions, ","); got != "health_status: healthy,health_status: unhealthy" {
+		t.Errorf("handled %q, want only the container events", got)
+	}
+	if f := <-filters; !strings.Contains(f, `"health_status"`) || !strings.Contains(f, `"container"`) {
+		t.Errorf("events filters = %s", f)
+	}
+}
+
+func TestWatchUnhealthyTriggersScan(t *testing.T) {
+	c, _ := newEventsClient(t)
+	go c.watchUnhealthy()
+
+	select {
+	case <-c.trigger:
+	case <-time.After(5 * time.Second):
+		t.Fatal("unhealthy event did not trigger a scan")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, path string, kind string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func issue(t *testing.T, tmpl *x509.Certificate, parent *x509.Certificate, signer *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, signer = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func tlsFixtures(t *testing.T) (string, tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	dir := t.TempDir()
+	expiry := time.Now().Add(time.Hour)
+
+	ca, caKey := issue(t, &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "autoheal test CA"}, NotAfter: expiry, IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil, nil)
+	server, serverKey := issue(t, &x509.Certificate{SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "docker"}, NotAfter: expiry, IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}, ca, caKey)
+	client, clientKey := issue(t, &x509.Certificate{SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "autoheal"}, NotAfter: expiry, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, ca, caKey)
+
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "cert.pem"), "CERTIFICATE", client.Raw)
+	der, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, "key.pem"), "EC PRIVATE KEY", der)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	return dir, tls.Certificate{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey}, pool
+}
+
+func TestDockerTLS(t *testing.T) {
+	dir, serverCert, pool := tlsFixtures(t)
+
+	daemon := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+	}))
+	daemon.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	daemon.StartTLS()
+	defer daemon.Close()
+
+	c := newTestClient(t, map[string]string{
+		"DOCKER_HOST":       "tcp://" + strings.TrimPrefix(daemon.URL, "https://"),
+		"DOCKER_TLS_VERIFY": "1",
+		"DOCKER_CERT_PATH":  dir,
+	})
+	if err := c.ping(); err != nil {
+		t.Errorf("ping over mutual TLS failed: %s", err)
+	}
+}
+
+func TestDockerTLSMissingFiles(t *testing.T) {
+	dir, _, _ := tlsFixtures(t)
+
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		broken := t.TempDir()
+		for _, f := range []string{"ca.pem", "cert.pem", "key.pem"} {
+			if f == name {
+				continue
+			}
+			b, err := os.ReadFile(filepath.Join(dir, f))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(broken, f), b, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := dockerTLS(&config{TLSVerify: "1", CertPath: broken}); err == nil {
+			t.Errorf("dockerTLS without %s did not fail", name)
+		}
+	}
+
+	if cfg, err := dockerTLS(&config{}); cfg != nil || err != nil {
+		t.Errorf("dockerTLS without DOCKER_TLS_VERIFY = %v, %v, want nil, nil", cfg, err)
+	}
+}
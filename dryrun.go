@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func (c *Client) dryRun(container Container, id string, t string, reason string, action string) {
+	containerLog(container.Name(), id).infof("%s Would %s container %s (%s) (dry-run).\n", t, action, container.Name(), id)
+
+	if c.cfg.MetricsEnabled {
+		c.dryRuns.Add(c.ctx, 1, attribute.String("container", container.Name()), attribute.String("action", action))
+	}
+
+	if c.cfg.DryRunNotify {
+		e := Event{Time: t, Container: container.Name(), Id: id, Reason: reason, Result: RESULT_DRY_RUN, Message: fmt.Sprintf("Would %s the container (dry-run)", action), EventId: newEventId(), Status: container.Status}
+		if err := c.notify(e); err != nil {
+			errorf("Failed to call webhook. %s\n", err)
+		}
+	}
+}
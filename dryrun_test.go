@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDryRunDoesNotRestart(t *testing.T) {
+	out := captureLog(t)
+	c, reader := newMeteredClient(t, map[string]string{"AUTOHEAL_DRY_RUN": "true"})
+	d := &fakeAPI{unhealthy: []Container{{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}}}
+	c.docker = d
+
+	if _, err := c.runOnce(c.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.calls) != 0 {
+		t.Errorf("docker calls in dry-run = %v, want none", d.calls)
+	}
+	if !strings.Contains(out.String(), "Would restart container /web (0123456789ab) (dry-run).") {
+		t.Errorf("log does not show the intended restart:\n%s", out)
+	}
+	if got := counters(t, reader, "restarts_skipped_dryrun"); got["/web"] != 1 {
+		t.Errorf("restarts_skipped_dryrun = %v, want 1 for /web", got)
+	}
+	if got := counters(t, reader, "restarts_success"); len(got) != 0 {
+		t.Errorf("restarts_success = %v in dry-run, want none", got)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ACTION_RESTART  = "restart"
+	ACTION_KILL     = "kill"
+	ACTION_RECREATE = "recreate"
+	ACTION_NOTIFY   = "notify"
+	ACTION_UPDATE   = "update"
+)
+
+func parseEscalation(value string) []string {
+	var chain []string
+	for _, step := range strings.Split(value, ",") {
+		switch step = strings.ToLower(strings.TrimSpace(step)); step {
+		case ACTION_RESTART, ACTION_KILL, ACTION_RECREATE, ACTION_NOTIFY:
+			chain = append(chain, step)
+		}
+	}
+
+	if len(chain) == 0 {
+		return []string{ACTION_RESTART}
+	}
+
+	return chain
+}
+
+func (c *Client) escalation(container Container) []string {
+	if chain, ok := container.Labels["autoheal.escalation"]; ok {
+		return parseEscalation(chain)
+	}
+
+	return c.cfg.Escalation
+}
+
+func (c *Client) nextAction(container Container) (string, bool) {
+	chain := c.escalation(container)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stateFor(container.Id)
+	switch {
+	case s.GaveUp:
+		return chain[len(chain)-1], false
+	case s.StepAt.IsZero():
+		s.Step = 0
+	case now.Sub(s.StepAt) < c.cfg.EscalationWindow:
+		return chain[s.Step], false
+	case s.Step < len(chain)-1:
+		s.Step++
+	}
+	s.StepAt = now
+
+	return chain[s.Step], true
+}
+
+func (c *Client) giveUp(container Container, id string, t string) {
+	c.mu.Lock()
+	c.stateFor(container.Id).GaveUp = true
+	c.mu.Unlock()
+	c.remediationFailed(container.Id)
+
+	chain := strings.Join(c.escalation(container), ", ")
+	e := Event{Time: t, Container: container.Name(), Id: id, Result: RESULT_ESCALATED, Message: fmt.Sprintf("Gave up after escalating through %s, the container needs a human", chain)}
+	if err := c.notify(e); err != nil {
+		errorf("Failed to call webhook. %s\n", err)
+	}
+}
+
+func (c *Client) killContainer(id string) error {
+	if err := c.dockerPost(id + "/kill"); err != nil {
+		return err
+	}
+
+	return c.dockerPost(id + "/start")
+}
+
+type rawInspect struct {
+	Name       string                     `json:"Name"`
+	Config     map[string]json.RawMessage `json:"Config"`
+	HostConfig map[string]json.RawMessage `json:"HostConfig"`
+	Mounts     []struct {
+		Type        string `json:"Type"`
+		Name        string `json:"Name"`
+		Destination string `json:"Destination"`
+	} `json:"Mounts"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAMConfig json.RawMessage `json:"IPAMConfig"`
+			Links      []string        `json:"Links"`
+			Aliases    []string        `json:"Aliases"`
+			DriverOpts json.RawMessage `json:"DriverOpts"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// anonymousVolumes binds the old container's anonymous volumes into the new
+// one, so recreating does not leave its data behind in a dangling volume.
+func (old rawInspect) anonymousVolumes() ([]byte, error) {
+	var volumes map[string]struct{}
+	var binds []string
+	var mounts []struct {
+		Target string `json:"Target"`
+	}
+	json.Unmarshal(old.Config["Volumes"], &volumes)
+	json.Unmarshal(old.HostConfig["Binds"], &binds)
+	json.Unmarshal(old.HostConfig["Mounts"], &mounts)
+
+	taken := map[string]bool{}
+	for _, bind := range binds {
+		if parts := strings.Split(bind, ":"); len(parts) > 1 {
+			taken[parts[1]] = true
+		}
+	}
+	for _, m := range mounts {
+		taken[m.Target] = true
+	}
+	for _, m := range old.Mounts {
+		if _, declared := volumes[m.Destination]; m.Type == "volume" && declared && !taken[m.Destination] {
+			binds = append(binds, m.Name+":"+m.Destination)
+		}
+	}
+
+	return json.Marshal(binds)
+}
+
+// recreateContainer replaces a container with a fresh one built from its
+// inspect output. The old container is renamed aside and only removed once
+// the replacement is running; any failure before that puts it back.
+func (c *Client) recreateContainer(id string, image string) (string, error) {
+	body, err := c.inspectRaw(id)
+	if err != nil {
+		return "", err
+	}
+
+	var old rawInspect
+	if err := json.Unmarshal(body, &old); err != nil {
+		return "", err
+	}
+	if old.HostConfig == nil {
+		old.HostConfig = map[string]json.RawMessage{}
+	}
+	if old.HostConfig["Binds"], err = old.anonymousVolumes(); err != nil {
+		return "", err
+	}
+
+	spec := map[string]any{}
+	for k, v := range old.Config {
+		spec[k] = v
+	}
+	if image != "" {
+		spec["Image"] = image
+	}
+	spec["HostConfig"] = old.HostConfig
+	spec["NetworkingConfig"] = map[string]any{"EndpointsConfig": old.NetworkSettings.Networks}
+
+	name := strings.TrimPrefix(old.Name, "/")
+	if err := c.dockerPost(id + "/rename?name=" + url.QueryEscape(name+"-autoheal-"+shortID(id))); err != nil {
+		return "", err
+	}
+
+	body, err = c.dockerDo(http.MethodPost, "create?name="+url.QueryEscape(name), spec)
+	if err != nil {
+		return "", c.restoreContainer(id, name, "", err)
+	}
+
+	var created struct {
+		Id string `json:"Id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return "", c.restoreContainer(id, name, "", err)
+	}
+
+	if err := c.dockerPost(id + "/stop?t=" + c.cfg.DefaultStopTimeout); err != nil {
+		return "", c.restoreContainer(id, name, created.Id, err)
+	}
+	c.expectStart(created.Id)
+	if err := c.dockerPost(created.Id + "/start"); err != nil {
+		return "", c.restoreContainer(id, name, created.Id, err)
+	}
+
+	if _, err := c.dockerDo(http.MethodDelete, id, nil); err != nil {
+		containerLog(name, shortID(id)).errorf("%s Failed to remove the replaced container %s (%s). %s\n", time.Now().Format(TIME_FORMAT), name, shortID(id), err)
+	}
+
+	return created.Id, nil
+}
+
+func (c *Client) restoreContainer(id string, name string, created string, cause error) error {
+	errs := []error{cause}
+	if created != "" {
+		if _, err := c.dockerDo(http.MethodDelete, created+"?force=true", nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := c.dockerPost(id + "/rename?name=" + url.QueryEscape(name)); err != nil {
+		errs = append(errs, err)
+	}
+	if created != "" {
+		c.expectStart(id)
+		if err := c.dockerPost(id + "/start"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// moveState hands a recreated container's escalation state and restart
+// history to its replacement, so the ladder carries on where it was.
+func (c *Client) moveState(from string, to string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.state[from]; ok {
+		c.state[to] = s
+		delete(c.state, from)
+	}
+	if h, ok := c.history[from]; ok {
+		c.history[to] = h
+		delete(c.history, from)
+	}
+}
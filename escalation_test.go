@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotifyStepInDryRun(t *testing.T) {
+	c := newFakeClient(t, &fakeAPI{}, map[string]string{"AUTOHEAL_DRY_RUN": "true"})
+
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}}
+	if got := c.act(c.ctx, container, shortID(container.Id), "", REASON_UNHEALTHY, ACTION_NOTIFY); got != RESULT_DRY_RUN {
+		t.Errorf("act(notify) in dry-run = %q, want %q", got, RESULT_DRY_RUN)
+	}
+	if s, ok := c.state[container.Id]; ok && s.GaveUp {
+		t.Error("dry-run gave up on the container")
+	}
+}
+
+func TestEscalationWindow(t *testing.T) {
+	if w := InitConfig().EscalationWindow; w != 0 {
+		t.Errorf("AUTOHEAL_ESCALATION_WINDOW defaults to %s, want 0", w)
+	}
+
+	c := newTestClient(t, map[string]string{"AUTOHEAL_ESCALATION": "restart,kill,notify", "AUTOHEAL_ESCALATION_WINDOW": "60"})
+
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}}
+	if action, ok := c.nextAction(container); action != ACTION_RESTART || !ok {
+		t.Fatalf("first step = %s %v, want restart", action, ok)
+	}
+	if action, ok := c.nextAction(container); action != ACTION_RESTART || ok {
+		t.Errorf("step inside the window = %s %v, want to wait on restart", action, ok)
+	}
+
+	c.state[container.Id].StepAt = time.Now().Add(-c.cfg.EscalationWindow)
+	if action, ok := c.nextAction(container); action != ACTION_KILL || !ok {
+		t.Errorf("step after the window = %s %v, want kill", action, ok)
+	}
+}
+
+type fakeDaemon struct {
+	mu         sync.Mutex
+	calls      []string
+	createFail bool
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	d.calls = append(d.calls, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/containers/"))
+	d.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/json"):
+		io.WriteString(w, `{"Name": "/web", "Config": {"Image": "nginx", "Volumes": {"/data": {}}}, "HostConfig": {"Binds": ["/etc/web:/etc/web:ro"]}, "Mounts": [{"Type": "volume", "Name": "anon", "Destination": "/data"}]}`)
+	case r.URL.Path == "/containers/create":
+		var spec struct {
+			HostConfig struct {
+				Binds []string `json:"Binds"`
+			} `json:"HostConfig"`
+		}
+		json.NewDecoder(r.Body).Decode(&spec)
+		if d.createFail || r.URL.Query().Get("name") != "web" || len(spec.HostConfig.Binds) != 2 || spec.HostConfig.Binds[1] != "anon:/data" {
+			http.Error(w, `{"message": "conflict"}`, http.StatusConflict)
+			return
+		}
+		io.WriteString(w, `{"Id": "fedcba9876543210"}`)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestRecreateContainer(t *testing.T) {
+	tests := []struct {
+		name       string
+		createFail bool
+		id         string
+		calls      []string
+	}{
+		{"created", false, "fedcba9876543210", []string{
+			"GET 0123456789abcdef/json",
+			"POST 0123456789abcdef/rename",
+			"POST create",
+			"POST 0123456789abcdef/stop",
+			"POST fedcba9876543210/start",
+			"DELETE 0123456789abcdef",
+		}},
+		{"create fails", true, "", []string{
+			"GET 0123456789abcdef/json",
+			"POST 0123456789abcdef/rename",
+			"POST create",
+			"POST 0123456789abcdef/rename",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDaemon{createFail: tt.createFail}
+			srv := httptest.NewServer(d)
+			defer srv.Close()
+
+			c := newTestClient(t, map[string]string{"DOCKER_HOST": "tcp://" + strings.TrimPrefix(srv.URL, "http://"), "METRICS_ENABLED": "false"})
+			id, err := c.recreateContainer("0123456789abcdef", "")
+			if id != tt.id || (err != nil) != tt.createFail {
+				t.Errorf("recreateContainer() = %q, %v, want %q", id, err, tt.id)
+			}
+			if strings.Join(d.calls, "\n") != strings.Join(tt.calls, "\n") {
+				t.Errorf("docker calls:\n%s\nwant:\n%s", strings.Join(d.calls, "\n"), strings.Join(tt.calls, "\n"))
+			}
+		})
+	}
+}
+
+func TestRecreateKeepsEscalation(t *testing.T) {
+	c := newFakeClient(t, &fakeAPI{}, map[string]string{"AUTOHEAL_ESCALATION": "restart,recreate,notify"})
+
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}}
+	c.stateFor(container.Id).Step = 1
+	c.act(c.ctx, container, shortID(container.Id), "", REASON_UNHEALTHY, ACTION_RECREATE)
+
+	if _, ok := c.state[container.Id]; ok {
+		t.Error("the replaced container still has escalation state")
+	}
+	if s, ok := c.state[container.Id+"-new"]; !ok || s.Step != 1 {
+		t.Errorf("the new container's escalation state = %+v, want step 1", s)
+	}
+}
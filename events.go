@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Publisher interface {
+	Publish(topic string, payload []byte) error
+}
+
+func NewPublisher(rawURL string, timeout time.Duration) (Publisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "nats":
+		return &natsPublisher{url: u, timeout: timeout}, nil
+	case "redis":
+		return &redisPublisher{url: u, timeout: timeout}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported event broker scheme %q", u.Scheme)
+}
+
+type natsPublisher struct {
+	url     *url.URL
+	timeout time.Duration
+}
+
+func (p *natsPublisher) Publish(topic string, payload []byte) error {
+	conn, err := net.DialTimeout("tcp", p.url.Host, p.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(p.timeout))
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO") {
+		return fmt.Errorf("unexpected nats greeting: %s", strings.TrimSpace(line))
+	}
+
+	opts := map[string]any{"verbose": false, "pedantic": false, "name": "docker-restart"}
+	if p.url.User != nil {
+		if pass, ok := p.url.User.Password(); ok {
+			opts["user"], opts["pass"] = p.url.User.Username(), pass
+		} else {
+			opts["auth_token"] = p.url.User.Username()
+		}
+	}
+	connect, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(conn, "CONNECT %s\r\nPUB %s %d\r\n%s\r\nPING\r\n", connect, topic, len(payload), payload)
+	if err != nil {
+		return err
+	}
+
+	for {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "PONG"):
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(line[4:]))
+		}
+	}
+}
+
+type redisPublisher struct {
+	url     *url.URL
+	timeout time.Duration
+}
+
+func (p *redisPublisher) command(w *bufio.Writer, r *bufio.Reader, args ...string) (string, error) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "-") {
+		return "", fmt.Errorf("redis: %s", line[1:])
+	}
+
+	return line, nil
+}
+
+func (p *redisPublisher) Publish(topic string, payload []byte) error {
+	conn, err := net.DialTimeout("tcp", p.url.Host, p.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(p.timeout))
+
+	w, r := bufio.NewWriter(conn), bufio.NewReader(conn)
+
+	if p.url.User != nil {
+		args := []string{"AUTH", p.url.User.Username()}
+		if pass, ok := p.url.User.Password(); ok {
+			args = append(args, pass)
+		}
+		if _, err := p.command(w, r, args...); err != nil {
+			return err
+		}
+	}
+
+	if db := strings.TrimPrefix(p.url.Path, "/"); db != "" {
+		if _, err := p.command(w, r, "SELECT", db); err != nil {
+			return err
+		}
+	}
+
+	_, err = p.command(w, r, "PUBLISH", topic, string(payload))
+	return err
+}
+
+func (c *Client) publish(e Event) error {
+	if c.pub == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(e)
+	if c.pubTmpl != nil {
+		payload, err = render(c.pubTmpl, e)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.pub.Publish(c.cfg.EventTopic, payload)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fakeNats(t *testing.T) (string, chan map[string]any) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	connects := make(chan map[string]any, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("INFO {}\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "CONNECT "):
+				opts := map[string]any{}
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &opts)
+				connects <- opts
+			case strings.HasPrefix(line, "PING"):
+				conn.Write([]byte("PONG\r\n"))
+				return
+			}
+		}
+	}()
+
+	return l.Addr().String(), connects
+}
+
+func TestNatsAuth(t *testing.T) {
+	tests := []struct {
+		userinfo string
+		want     map[string]string
+		absent   []string
+	}{
+		{"", nil, []string{"user", "pass", "auth_token"}},
+		{"s3cret@", map[string]string{"auth_token": "s3cret"}, []string{"user", "pass"}},
+		{"autoheal:s3cret@", map[string]string{"user": "autoheal", "pass": "s3cret"}, []string{"auth_token"}},
+	}
+
+	for _, tt := range tests {
+		addr, connects := fakeNats(t)
+		u, err := url.Parse("nats://" + tt.userinfo + addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := &natsPublisher{url: u, timeout: time.Second}
+		if err := p.Publish("autoheal.restarts", []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+		opts := <-connects
+		for k, v := range tt.want {
+			if opts[k] != v {
+				t.Errorf("%q: CONNECT %s = %v, want %q", tt.userinfo, k, opts[k], v)
+			}
+		}
+		for _, k := range tt.absent {
+			if _, ok := opts[k]; ok {
+				t.Errorf("%q: CONNECT sent %s", tt.userinfo, k)
+			}
+		}
+	}
+}
+
+type blockingPublisher struct {
+	release chan struct{}
+	topics  chan string
+}
+
+func (p *blockingPublisher) Publish(topic string, payload []byte) error {
+	<-p.release
+	p.topics <- topic
+	return nil
+}
+
+func TestPublishIsAsync(t *testing.T) {
+	c := newFakeClient(t, &fakeAPI{}, map[string]string{})
+	p := &blockingPublisher{release: make(chan struct{}), topics: make(chan string, 1)}
+	c.pub = p
+	c.pubQueue = newSinkQueue("publish event", c.publish)
+
+	done := make(chan struct{})
+	go func() {
+		c.notify(Event{Container: "/web", Id: "0123456789ab", Result: RESULT_SUCCESS})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notify waited on a broker that does not answer")
+	}
+
+	close(p.release)
+	c.pubQueue.drain()
+	select {
+	case topic := <-p.topics:
+		if topic != c.cfg.EventTopic {
+			t.Errorf("published to %q, want %q", topic, c.cfg.EventTopic)
+		}
+	default:
+		t.Error("the queued event was not published before drain returned")
+	}
+}
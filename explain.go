@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func (c *Client) explainAction(f *labelFilter, container Container, inspect *ContainerInspect, matches bool) string {
+	switch {
+	case !matches:
+		return "ignored, not labelled " + f.Label
+	case disabled(container):
+		return "ignored, autoheal.disable=true"
+	case inspect == nil:
+		return "unknown"
+	case container.State == RESTARTING:
+		return "none, already restarting"
+	}
+
+	if c.conditionOf(container) != "" {
+		met, err := c.evaluateCondition(container)
+		switch {
+		case err != nil:
+			return "unknown, " + err.Error()
+		case !met:
+			return "none, condition not met"
+		}
+	} else if !f.Monitors(inspect) {
+		if inspect.State.Health == nil {
+			return "none, no healthcheck"
+		}
+		return "none"
+	}
+
+	if wait := c.restartWait(container); wait > 0 {
+		return fmt.Sprintf("wait %s for cooldown", wait)
+	}
+	if !c.restartsEnabled() {
+		return "notify only, restarts disabled by flag"
+	}
+
+	action := c.escalation(container)[0]
+	if c.cfg.Debounce > 0 {
+		return fmt.Sprintf("%s after %s unhealthy", action, c.cfg.Debounce)
+	}
+
+	return action
+}
+
+func (c *Client) explain() error {
+	f, err := newLabelFilter(c.cfg.ContainerLabel, c.cfg.MonitorStates)
+	if err != nil {
+		return err
+	}
+
+	body, err := c.dockerDo(http.MethodGet, "json?all=true", nil)
+	if err != nil {
+		return err
+	}
+
+	var containers []Container
+	if err := json.Unmarshal(body, &containers); err != nil {
+		return err
+	}
+	c.sortByAge(containers)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tID\tSTATE\tHEALTH\tMONITORED\tACTION")
+	for _, container := range containers {
+		name := container.Name()
+		if name == "" {
+			name = shortID(container.Id)
+		}
+
+		inspect, err := c.inspectContainer(container.Id)
+		health := ""
+		if err == nil {
+			health = inspect.HealthStatus()
+		} else {
+			health = "unknown (" + err.Error() + ")"
+		}
+
+		matches := f.Matches(container)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", strings.TrimPrefix(name, "/"), shortID(container.Id), container.State, health, matches, c.explainAction(f, container, inspect, matches))
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestExplainAction(t *testing.T) {
+	c := newTestClient(t, nil)
+	f, err := newLabelFilter("autoheal,monitoring.restart", "unhealthy,exited")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unhealthy := &ContainerInspect{}
+	unhealthy.State.Health = &ContainerHealth{Status: "unhealthy"}
+	healthy := &ContainerInspect{}
+	healthy.State.Health = &ContainerHealth{Status: "healthy"}
+	exited := &ContainerInspect{}
+	exited.State.Status = "exited"
+	running := &ContainerInspect{}
+	running.State.Status = "running"
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		inspect *ContainerInspect
+		want    string
+	}{
+		{"unlabelled", nil, unhealthy, "ignored, not labelled autoheal,monitoring.restart"},
+		{"first label", map[string]string{"autoheal": "true"}, unhealthy, ACTION_RESTART},
+		{"second label", map[string]string{"monitoring.restart": "true"}, unhealthy, ACTION_RESTART},
+		{"disabled", map[string]string{"autoheal": "true", "autoheal.disable": "true"}, unhealthy, "ignored, autoheal.disable=true"},
+		{"healthy", map[string]string{"autoheal": "true"}, healthy, "none"},
+		{"exited state", map[string]string{"autoheal": "true"}, exited, ACTION_RESTART},
+		{"no healthcheck", map[string]string{"autoheal": "true"}, running, "none, no healthcheck"},
+	}
+
+	for _, tt := range tests {
+		container := Container{Id: "0123456789abcdef", Names: []string{"/" + tt.name}, Labels: tt.labels}
+		if got := c.explainAction(f, container, tt.inspect, f.Matches(container)); got != tt.want {
+			t.Errorf("%s: explainAction() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type remoteFlag struct {
+	Enabled   bool
+	FetchedAt time.Time
+}
+
+func (c *Client) fetchFlag() (bool, error) {
+	response, err := c.httpw.Get(c.cfg.FlagUrl)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return true, fmt.Errorf("flag endpoint returned status %d", response.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 4096))
+	if err != nil {
+		return true, err
+	}
+
+	var doc struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if json.Unmarshal(body, &doc) == nil && doc.Enabled != nil {
+		return *doc.Enabled, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(body))) {
+	case "disabled", "false", "off", "0":
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (c *Client) restartsEnabled() bool {
+	if c.cfg.FlagUrl == "" {
+		return true
+	}
+
+	c.flagMu.Lock()
+	defer c.flagMu.Unlock()
+
+	if time.Since(c.flag.FetchedAt) < c.cfg.FlagCacheTTL {
+		return c.flag.Enabled
+	}
+
+	enabled, err := c.fetchFlag()
+	if err != nil {
+		errorf("%s Failed to fetch the remote flag, restarts stay enabled. %s\n", time.Now().Format(TIME_FORMAT), err)
+	}
+
+	if enabled != c.flag.Enabled {
+		if enabled {
+			infof("%s Remote flag enabled restarts again.\n", time.Now().Format(TIME_FORMAT))
+		} else {
+			infof("%s Remote flag disabled restarts - notify only.\n", time.Now().Format(TIME_FORMAT))
+		}
+	}
+	c.flag = remoteFlag{Enabled: enabled, FetchedAt: time.Now()}
+
+	return enabled
+}
+
+func (c *Client) notifyOnly(container Container, id string, t string, reason string) {
+	containerLog(container.Name(), id).infof("%s Container %s (%s) %s - Restarts are disabled by the remote flag, don't restart.\n", t, container.Name(), id, reason)
+
+	c.mu.Lock()
+	s := c.stateFor(container.Id)
+	notify := !s.FlagNotified
+	s.FlagNotified = true
+	c.mu.Unlock()
+
+	if notify {
+		e := Event{Time: t, Container: container.Name(), Id: id, Reason: reason, Result: RESULT_BLOCKED, Message: "Restarts are disabled by the remote flag, not restarting the container"}
+		if err := c.notify(e); err != nil {
+			errorf("Failed to call webhook. %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestActHonoursRemoteFlag(t *testing.T) {
+	flag := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"enabled": false}`)
+	}))
+	defer flag.Close()
+
+	c := newTestClient(t, map[string]string{
+		"METRICS_ENABLED":   "false",
+		"AUTOHEAL_FLAG_URL": flag.URL,
+	})
+
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}}
+	if got := c.act(c.ctx, container, shortID(container.Id), "", REASON_SCHEDULED, ACTION_RESTART); got != RESULT_BLOCKED {
+		t.Errorf("act() = %q, want %q", got, RESULT_BLOCKED)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+func (c *Client) recordPoll(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastPollOK = err == nil
+	c.lastPollTime = time.Now()
+}
+
+func (c *Client) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	ok, at := c.lastPollOK, c.lastPollTime
+	c.mu.Unlock()
+
+	switch {
+	case !c.ready.Load():
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	case !ok:
+		http.Error(w, fmt.Sprintf("last poll failed at %s", at.Format(TIME_FORMAT)), http.StatusServiceUnavailable)
+	default:
+		fmt.Fprintf(w, "ok, last poll at %s\n", at.Format(TIME_FORMAT))
+	}
+}
+
+func (c *Client) serveHealth(l net.Listener) {
+	infof("%s Serving health at : %s /healthz\n", time.Now().Format(TIME_FORMAT), c.cfg.HealthPort)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", c.handleHealthz)
+	err := http.Serve(l, mux)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
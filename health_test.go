@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthz(t *testing.T) {
+	c := newTestClient(t, map[string]string{"METRICS_ENABLED": "false"})
+	healthz := func() (int, string) {
+		w := httptest.NewRecorder()
+		c.handleHealthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return w.Code, w.Body.String()
+	}
+
+	if code, _ := healthz(); code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz before init = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	c.ready.Store(true)
+	c.recordPoll(nil)
+	if code, body := healthz(); code != http.StatusOK || !strings.HasPrefix(body, "ok") {
+		t.Errorf("/healthz after a good poll = %d %q, want 200 ok", code, body)
+	}
+
+	c.recordPoll(errors.New("connection refused"))
+	if code, body := healthz(); code != http.StatusServiceUnavailable || !strings.Contains(body, "last poll failed") {
+		t.Errorf("/healthz after a failed poll = %d %q, want 503", code, body)
+	}
+
+	c.recordPoll(nil)
+	if code, _ := healthz(); code != http.StatusOK {
+		t.Errorf("/healthz after the daemon came back = %d, want 200", code)
+	}
+}
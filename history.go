@@ -0,0 +1,13 @@
+package main
+
+import "time"
+
+type historyRecord struct {
+	Time      time.Time
+	Container string
+	Image     string
+	Action    string
+	Result    string
+	Duration  time.Duration
+	EventId   string
+}
@@ -0,0 +1,48 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type historyDB struct {
+	db *sql.DB
+}
+
+func openHistoryDB(path string) (*historyDB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS restarts (
+		timestamp TEXT NOT NULL,
+		container TEXT NOT NULL,
+		image TEXT,
+		action TEXT NOT NULL,
+		result TEXT NOT NULL,
+		duration REAL NOT NULL,
+		event_id TEXT
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &historyDB{db: db}, nil
+}
+
+func (h *historyDB) Record(r historyRecord) error {
+	if h == nil {
+		return nil
+	}
+
+	_, err := h.db.Exec(`INSERT INTO restarts (timestamp, container, image, action, result, duration, event_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		r.Time.UTC().Format(time.RFC3339), r.Container, r.Image, r.Action, r.Result, r.Duration.Seconds(), r.EventId)
+
+	return err
+}
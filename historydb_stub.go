@@ -0,0 +1,15 @@
+//go:build !sqlite
+
+package main
+
+import "errors"
+
+type historyDB struct{}
+
+func openHistoryDB(path string) (*historyDB, error) {
+	return nil, errors.New("HISTORY_DB requires a build with -tags sqlite")
+}
+
+func (h *historyDB) Record(r historyRecord) error {
+	return nil
+}
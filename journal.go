@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+)
+
+const JOURNAL_SOCKET = "/run/systemd/journal/socket"
+
+const (
+	PRIORITY_ERR     = 3
+	PRIORITY_WARNING = 4
+	PRIORITY_NOTICE  = 5
+	PRIORITY_INFO    = 6
+	PRIORITY_DEBUG   = 7
+)
+
+var logOutput io.Writer = os.Stdout
+
+type journal struct {
+	conn *net.UnixConn
+}
+
+func NewJournal() (*journal, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: JOURNAL_SOCKET, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal{conn: conn}, nil
+}
+
+func appendField(buf *bytes.Buffer, key string, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", key, value)
+		return
+	}
+
+	buf.WriteString(key + "\n")
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
+
+func (j *journal) Send(priority int, message string, fields map[string]string) error {
+	var buf bytes.Buffer
+	appendField(&buf, "MESSAGE", message)
+	appendField(&buf, "PRIORITY", fmt.Sprint(priority))
+	appendField(&buf, "SYSLOG_IDENTIFIER", "docker-restart")
+	for k, v := range fields {
+		appendField(&buf, k, v)
+	}
+
+	_, err := j.conn.Write(buf.Bytes())
+	return err
+}
+
+func journalPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return PRIORITY_ERR
+	case level >= slog.LevelWarn:
+		return PRIORITY_WARNING
+	case level >= slog.LevelInfo:
+		return PRIORITY_INFO
+	}
+
+	return PRIORITY_DEBUG
+}
+
+func (j *journal) Write(p []byte) (int, error) {
+	j.Log(slog.LevelInfo, string(p), nil)
+
+	return len(p), nil
+}
+
+func (j *journal) Log(level slog.Level, message string, attrs []any) {
+	fields := map[string]string{}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		switch attrs[i] {
+		case "container":
+			fields["CONTAINER_NAME"] = fmt.Sprint(attrs[i+1])
+		case "id":
+			fields["CONTAINER_ID"] = fmt.Sprint(attrs[i+1])
+		}
+	}
+
+	if err := j.Send(journalPriority(level), strings.TrimRight(message, "\n"), fields); err != nil {
+		io.WriteString(os.Stdout, message)
+	}
+}
+
+func (c *Client) logEvent(e Event) {
+	if c.journal == nil {
+		if c.cfg.LogFormat == "json" {
+			c.logStructured(e)
+			return
+		}
+		containerLog(e.Container, e.Id).logf(eventLevel(e), "%s\n", e)
+		return
+	}
+
+	priority := PRIORITY_ERR
+	switch e.Result {
+	case RESULT_SUCCESS, RESULT_RECOVERED, RESULT_SUMMARY:
+		priority = PRIORITY_NOTICE
+	}
+
+	err := c.journal.Send(priority, e.String(), map[string]string{
+		"CONTAINER_NAME":    strings.TrimPrefix(e.Container, "/"),
+		"CONTAINER_ID":      e.Id,
+		"AUTOHEAL_RESULT":   e.Result,
+		"AUTOHEAL_EVENT_ID": e.EventId,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stdout, e)
+	}
+}
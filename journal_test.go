@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournalPriority(t *testing.T) {
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "journal.sock"), Net: "unixgram"}
+	server, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := logOutput
+	logOutput = &journal{conn: conn}
+	defer func() { logOutput = old }()
+
+	tests := []struct {
+		log    func()
+		fields []string
+	}{
+		{func() {
+			containerLog("/Failed-app", "0123456789ab").infof("Container /Failed-app (0123456789ab) recovered.\n")
+		}, []string{"PRIORITY=6", "CONTAINER_NAME=Failed-app", "CONTAINER_ID=0123456789ab"}},
+		{func() {
+			containerLog("/web", "0123456789ab").warnf("Container /web (0123456789ab) could not be re-inspected.\n")
+		}, []string{"PRIORITY=4", "CONTAINER_NAME=web"}},
+		{func() { errorf("Failed to call webhook. timeout\n") }, []string{"PRIORITY=3", "MESSAGE=Failed to call webhook. timeout\n"}},
+	}
+
+	buf := make([]byte, 4096)
+	for _, tt := range tests {
+		tt.log()
+		n, err := server.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range tt.fields {
+			if !strings.Contains(string(buf[:n]), field) {
+				t.Errorf("journal entry %q lacks %q", buf[:n], field)
+			}
+		}
+	}
+}
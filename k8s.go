@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const SERVICE_ACCOUNT = "/var/run/secrets/kubernetes.io/serviceaccount/"
+
+type k8sEvents struct {
+	http      http.Client
+	url       string
+	token     string
+	namespace string
+	node      string
+}
+
+func NewK8sEvents(timeout time.Duration) (*k8sEvents, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running inside a kubernetes cluster")
+	}
+
+	token, err := os.ReadFile(SERVICE_ACCOUNT + "token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(SERVICE_ACCOUNT + "ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := os.ReadFile(SERVICE_ACCOUNT + "namespace")
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in %sca.crt", SERVICE_ACCOUNT)
+	}
+
+	node := os.Getenv("NODE_NAME")
+	if node == "" {
+		node, _ = os.Hostname()
+	}
+
+	return &k8sEvents{
+		http: http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		url:       "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: strings.TrimSpace(string(namespace)),
+		node:      node,
+	}, nil
+}
+
+func (k *k8sEvents) Emit(e Event, format *template.Template) error {
+	if k == nil {
+		return nil
+	}
+
+	message := e.String()
+	if format != nil {
+		b, err := render(format, e)
+		if err != nil {
+			return err
+		}
+		message = string(b)
+	}
+
+	kind, reason := "Normal", "ContainerRestarted"
+	if e.Result != RESULT_SUCCESS {
+		kind, reason = "Warning", "ContainerRestartFailed"
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]any{
+			"generateName": "docker-restart.",
+			"namespace":    k.namespace,
+		},
+		"involvedObject": map[string]any{
+			"kind": "Node",
+			"name": k.node,
+		},
+		"reason":         reason,
+		"message":        message,
+		"type":           kind,
+		"count":          1,
+		"firstTimestamp": now,
+		"lastTimestamp":  now,
+		"source": map[string]any{
+			"component": "docker-restart",
+			"host":      k.node,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, k.url+"/api/v1/namespaces/"+k.namespace+"/events", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+k.token)
+	request.Header.Set("Content-Type", CONTENT_TYPE)
+
+	response, err := k.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("kubernetes returned status %d: %s", response.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
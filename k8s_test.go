@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestK8sEventsAreAsync(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan map[string]any, 1)
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		if r.URL.Path != "/api/v1/namespaces/default/events" || r.Header.Get("Authorization") != "Bearer t0ken" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		event := map[string]any{}
+		json.NewDecoder(r.Body).Decode(&event)
+		received <- event
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer api.Close()
+
+	c := newFakeClient(t, &fakeAPI{}, map[string]string{})
+	c.k8s = &k8sEvents{http: *api.Client(), url: api.URL, token: "t0ken", namespace: "default", node: "node-1"}
+	c.k8sQueue = newSinkQueue("create kubernetes event", func(e Event) error { return c.k8s.Emit(e, c.k8sTmpl) })
+
+	done := make(chan struct{})
+	go func() {
+		c.notify(Event{Container: "/web", Id: "0123456789ab", Result: RESULT_SUCCESS, Message: "Successfully restarted the container"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notify waited on the kubernetes API")
+	}
+
+	close(release)
+	c.k8sQueue.drain()
+	select {
+	case event := <-received:
+		if event["reason"] != "ContainerRestarted" || event["type"] != "Normal" {
+			t.Errorf("event = %v, want a Normal ContainerRestarted event", event)
+		}
+	default:
+		t.Error("the queued event was not sent before drain returned")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const DAY = 24 * time.Hour
+
+type restartHistory struct {
+	Restarts      []time.Time
+	Escalated     bool
+	Abandoned     bool
+	Streak        int
+	LastUnhealthy time.Time
+	RecoveredAt   time.Time
+}
+
+func (h *restartHistory) since(window time.Duration) int {
+	cutoff := time.Now().Add(-window)
+	n := 0
+	for _, r := range h.Restarts {
+		if !r.Before(cutoff) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// attempts counts the restarts in the window since the container last
+// recovered, so a container that flaps slowly starts over each time.
+func (h *restartHistory) attempts(window time.Duration) int {
+	cutoff := time.Now().Add(-window)
+	n := 0
+	for _, r := range h.Restarts {
+		if !r.Before(cutoff) && r.After(h.RecoveredAt) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (c *Client) scaledCooldown(cooldown time.Duration, streak int) time.Duration {
+	for i := 1; i < streak && i <= 10 && c.cfg.CooldownMultiplier > 1; i++ {
+		cooldown *= time.Duration(c.cfg.CooldownMultiplier)
+	}
+
+	return cooldown
+}
+
+func (c *Client) dailyLimit(container Container) int {
+	limit, err := strconv.Atoi(container.Labels["autoheal.max_restarts_per_day"])
+	if err != nil || limit < 1 {
+		return 0
+	}
+
+	return limit
+}
+
+func (c *Client) withinDailyLimit(container Container, id string, t string) bool {
+	limit := c.dailyLimit(container)
+	if limit == 0 {
+		return true
+	}
+
+	c.mu.Lock()
+	h := c.history[container.Id]
+	if h == nil || h.since(DAY) < limit {
+		if h != nil {
+			h.Escalated = false
+		}
+		c.mu.Unlock()
+		return true
+	}
+	escalate := !h.Escalated
+	h.Escalated = true
+	c.mu.Unlock()
+
+	containerLog(container.Name(), id).warnf("%s Container %s (%s) reached its daily limit of %d restarts - don't restart.\n", t, container.Name(), id, limit)
+	if escalate {
+		c.remediationFailed(container.Id)
+		e := Event{Time: t, Container: container.Name(), Id: id, Result: RESULT_ESCALATED, Message: fmt.Sprintf("Reached the limit of %d restarts per day, no more restarts until the window rolls", limit)}
+		if err := c.notify(e); err != nil {
+			errorf("Failed to call webhook. %s\n", err)
+		}
+	}
+
+	return false
+}
+
+func (c *Client) withinAttempts(container Container, id string, t string) bool {
+	if c.cfg.MaxAttempts < 1 {
+		return true
+	}
+
+	c.mu.Lock()
+	h := c.history[container.Id]
+	if h == nil || h.attempts(c.cfg.AttemptsWindow) < c.cfg.MaxAttempts {
+		if h != nil {
+			h.Abandoned = false
+		}
+		c.mu.Unlock()
+		return true
+	}
+	abandon := !h.Abandoned
+	h.Abandoned = true
+	c.mu.Unlock()
+
+	if !abandon {
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) was given up on after %d restarts in %s - don't restart.\n", t, container.Name(), id, c.cfg.MaxAttempts, c.cfg.AttemptsWindow)
+		return false
+	}
+
+	containerLog(container.Name(), id).warnf("%s Container %s (%s) is still unhealthy after %d restarts in %s - Giving up.\n", t, container.Name(), id, c.cfg.MaxAttempts, c.cfg.AttemptsWindow)
+	c.remediationFailed(container.Id)
+	if c.cfg.MetricsEnabled {
+		c.abandoned.Add(c.ctx, 1, attribute.String("container", container.Name()))
+	}
+	e := Event{Time: t, Container: container.Name(), Id: id, Result: RESULT_ESCALATED, Message: fmt.Sprintf("Giving up after %d restarts in %s, the container needs a human", c.cfg.MaxAttempts, c.cfg.AttemptsWindow)}
+	if err := c.notify(e); err != nil {
+		errorf("Failed to call webhook. %s\n", err)
+	}
+
+	return false
+}
+
+func (c *Client) recordHistory(id string) {
+	h := c.history[id]
+	if h == nil {
+		h = &restartHistory{}
+		c.history[id] = h
+	}
+	now := time.Now()
+	if len(h.Restarts) > 0 && now.Sub(h.Restarts[len(h.Restarts)-1]) <= c.cfg.FlapWindow {
+		h.Streak++
+	} else {
+		h.Streak = 1
+	}
+	h.Restarts = append(h.Restarts, now)
+	h.LastUnhealthy = now
+}
+
+func (c *Client) pruneHistory() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-max(DAY, c.cfg.AttemptsWindow))
+	for id, h := range c.history {
+		if now.Sub(h.LastUnhealthy) >= c.cfg.FlapWindow {
+			h.Streak = 0
+		}
+
+		i := 0
+		for i < len(h.Restarts) && h.Restarts[i].Before(cutoff) {
+			i++
+		}
+		h.Restarts = h.Restarts[i:]
+		if len(h.Restarts) == 0 {
+			delete(c.history, id)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttemptsResetAfterRecovery(t *testing.T) {
+	out := captureLog(t)
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}}
+	running := &ContainerInspect{}
+	running.State.Running = true
+	d := &fakeAPI{inspects: map[string]*ContainerInspect{container.Id: running}}
+	c := newFakeClient(t, d, map[string]string{"AUTOHEAL_MAX_ATTEMPTS": "2"})
+
+	now := time.Now()
+	c.history[container.Id] = &restartHistory{Restarts: []time.Time{now.Add(-2 * time.Minute), now.Add(-time.Minute)}}
+	for i := 0; i < 3; i++ {
+		if c.withinAttempts(container, shortID(container.Id), "") {
+			t.Fatal("allowed a restart past AUTOHEAL_MAX_ATTEMPTS")
+		}
+	}
+	if n := strings.Count(out.String(), "- Giving up."); n != 1 {
+		t.Errorf("logged Giving up %d times, want once at the transition", n)
+	}
+
+	c.reportRecovered(map[string]string{container.Id: container.Name()})
+	if !c.withinAttempts(container, shortID(container.Id), "") {
+		t.Error("attempts did not reset after the container recovered")
+	}
+
+	c.mu.Lock()
+	c.recordHistory(container.Id)
+	c.mu.Unlock()
+	if !c.withinAttempts(container, shortID(container.Id), "") {
+		t.Error("one restart after recovery counted against the earlier ones")
+	}
+}
+
+func TestScheduledRestartsNotCounted(t *testing.T) {
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}
+	running := &ContainerInspect{}
+	running.State.Running = true
+	d := &fakeAPI{inspects: map[string]*ContainerInspect{container.Id: running}}
+	c := newFakeClient(t, d, map[string]string{"AUTOHEAL_MAX_ATTEMPTS": "1"})
+
+	for i := 0; i < 3; i++ {
+		if got := c.act(c.ctx, container, shortID(container.Id), "", REASON_SCHEDULED, ACTION_RESTART); got != RESULT_SUCCESS {
+			t.Fatalf("scheduled restart %d = %s, want %s", i, got, RESULT_SUCCESS)
+		}
+	}
+	if !c.withinAttempts(container, shortID(container.Id), "") {
+		t.Error("scheduled restarts counted towards AUTOHEAL_MAX_ATTEMPTS")
+	}
+
+	c.act(c.ctx, container, shortID(container.Id), "", REASON_UNHEALTHY, ACTION_RESTART)
+	if c.withinAttempts(container, shortID(container.Id), "") {
+		t.Error("an unhealthy restart did not count towards AUTOHEAL_MAX_ATTEMPTS")
+	}
+	budget := 10
+	if _, ok := c.admit(container, REASON_SCHEDULED, map[string]int{}, &budget, shortID(container.Id), ""); !ok {
+		t.Error("a scheduled restart was given up on with the unhealthy attempts")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const LOADAVG = "/proc/loadavg"
+
+func hostLoad() (float64, error) {
+	b, err := os.ReadFile(LOADAVG)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected %s content", LOADAVG)
+	}
+
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+func (c *Client) loadBudget() int {
+	if c.cfg.LoadThreshold <= 0 {
+		return -1
+	}
+
+	t := time.Now().Format(TIME_FORMAT)
+	load, err := hostLoad()
+	if err != nil {
+		errorf("%s Failed to read host load. %s\n", t, err)
+		return -1
+	}
+
+	perCPU := load / float64(runtime.NumCPU())
+	if perCPU <= c.cfg.LoadThreshold {
+		return -1
+	}
+
+	infof("%s Host load is %.2f per CPU, above %.2f - Limiting restarts to %d this cycle.\n", t, perCPU, c.cfg.LoadThreshold, c.cfg.LoadMaxRestarts)
+	return c.cfg.LoadMaxRestarts
+}
+
+func (c *Client) loadThrottled(container Container, budget *int, id string, t string) bool {
+	if *budget < 0 {
+		return false
+	}
+
+	if *budget > 0 {
+		*budget--
+		return false
+	}
+
+	containerLog(container.Name(), id).infof("%s Container %s (%s) deferred - Host is under heavy load.\n", t, container.Name(), id)
+	if c.cfg.MetricsEnabled {
+		c.deferred.Add(c.ctx, 1, attribute.String("reason", "load"))
+	}
+	c.statsd.Count("restarts_deferred", map[string]string{"container": container.Name(), "reason": "load"})
+
+	return true
+}
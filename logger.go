@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+var logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+var logLevel = new(slog.LevelVar)
+
+// leveledWriter is implemented by log outputs that keep the level and
+// attributes of a line instead of just its text.
+type leveledWriter interface {
+	Log(level slog.Level, message string, attrs []any)
+}
+
+type slogWriter struct {
+	logger *slog.Logger
+}
+
+// logEntry carries the attributes of a log line, so outputs never have to
+// parse them back out of the message.
+type logEntry struct {
+	attrs []any
+}
+
+func newLogger(format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: logLevel}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+	}
+
+	return slog.New(slog.NewTextHandler(os.Stdout, opts))
+}
+
+func parseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(name))
+
+	return level, err
+}
+
+func containerLog(name string, id string) logEntry {
+	return logEntry{attrs: []any{"container", strings.TrimPrefix(name, "/"), "id", id}}
+}
+
+func (e logEntry) logf(level slog.Level, format string, args ...any) {
+	if level < logLevel.Level() {
+		return
+	}
+
+	message := fmt.Sprintf(format, args...)
+	if w, ok := logOutput.(leveledWriter); ok {
+		w.Log(level, message, e.attrs)
+		return
+	}
+	io.WriteString(logOutput, message)
+}
+
+func (e logEntry) debugf(format string, args ...any) { e.logf(slog.LevelDebug, format, args...) }
+func (e logEntry) infof(format string, args ...any)  { e.logf(slog.LevelInfo, format, args...) }
+func (e logEntry) warnf(format string, args ...any)  { e.logf(slog.LevelWarn, format, args...) }
+func (e logEntry) errorf(format string, args ...any) { e.logf(slog.LevelError, format, args...) }
+
+func debugf(format string, args ...any) { logEntry{}.debugf(format, args...) }
+func infof(format string, args ...any)  { logEntry{}.infof(format, args...) }
+func warnf(format string, args ...any)  { logEntry{}.warnf(format, args...) }
+func errorf(format string, args ...any) { logEntry{}.errorf(format, args...) }
+
+func (w slogWriter) Write(p []byte) (int, error) {
+	w.Log(slog.LevelInfo, string(p), nil)
+
+	return len(p), nil
+}
+
+func (w slogWriter) Log(level slog.Level, message string, attrs []any) {
+	message = strings.TrimRight(message, "\n")
+	if len(message) > len(TIME_FORMAT) {
+		if _, err := time.Parse(TIME_FORMAT, message[:len(TIME_FORMAT)]); err == nil {
+			message = message[len(TIME_FORMAT)+1:]
+		}
+	}
+
+	w.logger.Log(context.Background(), level, message, attrs...)
+}
+
+func eventLevel(e Event) slog.Level {
+	switch e.Result {
+	case RESULT_SUCCESS, RESULT_RECOVERED, RESULT_SUMMARY, RESULT_DRY_RUN:
+		return slog.LevelInfo
+	}
+
+	return slog.LevelError
+}
+
+func (c *Client) logStructured(e Event) {
+	attrs := []any{"outcome", e.Result}
+	if e.Container != "" {
+		attrs = append(attrs, "container", strings.TrimPrefix(e.Container, "/"), "id", e.Id)
+	}
+	if e.Status != "" {
+		attrs = append(attrs, "state", e.Status)
+	}
+	if e.Reason != "" {
+		attrs = append(attrs, "reason", e.Reason)
+	}
+	if e.EventId != "" {
+		attrs = append(attrs, "event_id", e.EventId)
+	}
+	logger.Log(context.Background(), eventLevel(e), e.Message, attrs...)
+}
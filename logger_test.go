@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStructuredLogLevels(t *testing.T) {
+	var buf syncBuffer
+	old := logOutput
+	logOutput = slogWriter{logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: logLevel}))}
+	defer func() { logOutput = old }()
+
+	now := time.Now().Format(TIME_FORMAT)
+	containerLog("/Failed-app", "0123456789ab").infof("%s Container %s (%s) recovered.\n", now, "/Failed-app", "0123456789ab")
+	errorf("Failed to call webhook. %s\n", "timeout")
+	containerLog("/web", "fedcba987654").warnf("%s Container %s (%s) could not be re-inspected - don't restart.\n", now, "/web", "fedcba987654")
+	debugf("%s Container %s (%s) found to be restarting - don't restart.\n", now, "/web", "fedcba987654")
+
+	want := []struct {
+		level, msg, container, id string
+	}{
+		{"INFO", "Container /Failed-app (0123456789ab) recovered.", "Failed-app", "0123456789ab"},
+		{"ERROR", "Failed to call webhook. timeout", "", ""},
+		{"WARN", "Container /web (fedcba987654) could not be re-inspected - don't restart.", "web", "fedcba987654"},
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("logged %d lines, want %d (debug filtered out):\n%s", len(lines), len(want), buf.String())
+	}
+	for i, line := range lines {
+		var got struct {
+			Level     string `json:"level"`
+			Msg       string `json:"msg"`
+			Container string `json:"container"`
+			Id        string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Level != want[i].level || got.Msg != want[i].msg || got.Container != want[i].container || got.Id != want[i].id {
+			t.Errorf("line %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
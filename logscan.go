@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type logScanState struct {
+	Since   time.Time
+	Matches []time.Time
+}
+
+func demuxLogs(data []byte) string {
+	var sb strings.Builder
+	for len(data) >= 8 {
+		if data[0] > 2 || data[1] != 0 || data[2] != 0 || data[3] != 0 {
+			break
+		}
+		size := int(binary.BigEndian.Uint32(data[4:8]))
+		if len(data) < 8+size {
+			break
+		}
+		sb.Write(data[8 : 8+size])
+		data = data[8+size:]
+	}
+	sb.Write(data)
+
+	return sb.String()
+}
+
+func (c *Client) containerLogs(id string, query string) (string, error) {
+	response, err := c.httpd.Get(c.cfg.BaseUrl + id + "/logs?stdout=1&stderr=1&" + query)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("logs returned status %d: %s", response.StatusCode, body)
+	}
+
+	return demuxLogs(body), nil
+}
+
+func (c *Client) captureLogs(container Container, id string, t string) string {
+	if c.cfg.CaptureLogs <= 0 {
+		return ""
+	}
+
+	logs, err := c.docker.logs(container.Id, fmt.Sprintf("tail=%d", c.cfg.CaptureLogs))
+	if err != nil {
+		containerLog(container.Name(), id).errorf("%s Failed to capture the logs of container %s (%s). %s\n", t, container.Name(), id, err)
+		return ""
+	}
+	logs = strings.TrimRight(logs, "\n")
+	containerLog(container.Name(), id).debugf("%s Last %d log lines of container %s (%s):\n%s\n", t, c.cfg.CaptureLogs, container.Name(), id, logs)
+
+	return logs
+}
+
+func (c *Client) logMatches(container Container, now time.Time) (int, int, error) {
+	pattern, err := regexp.Compile(container.Labels["autoheal.log.pattern"])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	threshold, err := strconv.Atoi(container.Labels["autoheal.log.threshold"])
+	if err != nil || threshold < 1 {
+		threshold = 1
+	}
+	window, err := strconv.Atoi(container.Labels["autoheal.log.window"])
+	if err != nil || window < 1 {
+		window = 60
+	}
+
+	s, ok := c.logScans[container.Id]
+	if !ok {
+		c.logScans[container.Id] = &logScanState{Since: now}
+		return 0, threshold, nil
+	}
+
+	since := fmt.Sprintf("since=%d.%09d", s.Since.Unix(), s.Since.Nanosecond())
+	logs, err := c.docker.logs(container.Id, since)
+	if err != nil {
+		return 0, threshold, err
+	}
+	s.Since = now
+
+	for _, line := range strings.Split(logs, "\n") {
+		if pattern.MatchString(line) {
+			s.Matches = append(s.Matches, now)
+		}
+	}
+
+	cutoff := now.Add(-time.Duration(window) * time.Second)
+	i := 0
+	for i < len(s.Matches) && s.Matches[i].Before(cutoff) {
+		i++
+	}
+	s.Matches = s.Matches[i:]
+
+	return len(s.Matches), threshold, nil
+}
+
+func (c *Client) scanLogs(f *labelFilter) []candidate {
+	if !c.cfg.LogScan {
+		return nil
+	}
+
+	qs := map[string][]string{"label": []string{"autoheal.log.pattern"}}
+	containers, err := c.listFiltered(f, qs)
+	if err != nil {
+		errorf("Failed to list containers for log scanning. %s\n", err)
+		return nil
+	}
+
+	var hits []candidate
+	current := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		current[container.Id] = true
+		if disabled(container) {
+			continue
+		}
+
+		now := time.Now()
+		t := now.Format(TIME_FORMAT)
+		id := shortID(container.Id)
+
+		if container.Name() == "" || container.State != "running" {
+			continue
+		}
+
+		matches, threshold, err := c.logMatches(container, now)
+		if err != nil {
+			containerLog(container.Name(), id).errorf("%s Failed to scan logs of container %s (%s). %s\n", t, container.Name(), id, err)
+			continue
+		}
+		if matches < threshold {
+			continue
+		}
+
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) logged %d lines matching its pattern.\n", t, container.Name(), id, matches)
+		hits = append(hits, candidate{Container: container, Reason: "matched its log pattern"})
+		c.logScans[container.Id].Matches = nil
+	}
+
+	for id := range c.logScans {
+		if !current[id] {
+			delete(c.logScans, id)
+		}
+	}
+
+	return hits
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogScanHitsAreGated(t *testing.T) {
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running", Labels: map[string]string{"autoheal.log.pattern": "FATAL"}}
+	d := &fakeAPI{
+		listed: func(filters string) []Container {
+			if strings.Contains(filters, "autoheal.log.pattern") {
+				return []Container{container}
+			}
+			return nil
+		},
+		output: "FATAL out of connections\n",
+	}
+	c := newFakeClient(t, d, map[string]string{
+		"AUTOHEAL_LOG_SCAN":     "true",
+		"AUTOHEAL_MAX_ATTEMPTS": "1",
+	})
+	c.history[container.Id] = &restartHistory{Restarts: []time.Time{time.Now().Add(-time.Minute)}}
+
+	for i := 0; i < 2; i++ {
+		c.runOnce(c.ctx)
+	}
+	if n := d.count("restart"); n != 0 {
+		t.Fatalf("log scan hit past AUTOHEAL_MAX_ATTEMPTS reached the daemon %d time(s)", n)
+	}
+
+	delete(c.history, container.Id)
+	c.runOnce(c.ctx)
+	if n := d.count("restart"); n != 1 {
+		t.Errorf("log scan hit reached the daemon %d time(s), want 1", n)
+	}
+}
+
+func frame(stream byte, payload string) []byte {
+	header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+
+	return append(header, payload...)
+}
+
+func TestDemuxLogs(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, ""},
+		{"stdout", frame(1, "ready\n"), "ready\n"},
+		{"interleaved", append(append(frame(1, "starting\n"), frame(2, "FATAL no db\n")...), frame(1, "exiting\n")...), "starting\nFATAL no db\nexiting\n"},
+		{"empty frame", append(frame(1, ""), frame(2, "oops\n")...), "oops\n"},
+		{"tty", []byte("plain tty output\n"), "plain tty output\n"},
+		{"truncated", append(frame(1, "whole\n"), frame(2, "cut off\n")[:10]...), "whole\n" + string(frame(2, "cut off\n")[:10])},
+	}
+
+	for _, tt := range tests {
+		if got := demuxLogs(tt.data); got != tt.want {
+			t.Errorf("%s: demuxLogs() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
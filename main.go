@@ -3,63 +3,309 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric/instrument"
 	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
 	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	UNIX         = "unix"
 	NULL         = "null"
 	RESTARTING   = "restarting"
-	BASE_URL     = "http://unix/containers/"
+	MODE_POLL    = "poll"
+	MODE_EVENTS  = "events"
 	FILTER       = "json?filters="
 	COMMAND      = "/restart?t="
 	CONTENT_TYPE = "application/json"
 	TIME_FORMAT  = "2006.01.02 15:04:05"
 )
 
+const (
+	URGENCY_HIGH   = "high"
+	URGENCY_NORMAL = "normal"
+	URGENCY_LOW    = "low"
+)
+
+const (
+	RESULT_SUCCESS   = "success"
+	RESULT_FAILURE   = "failure"
+	RESULT_ESCALATED = "escalated"
+	RESULT_BLOCKED   = "blocked"
+	RESULT_RECOVERED = "recovered"
+	RESULT_FAILED    = "failed"
+	RESULT_SUMMARY   = "summary"
+	RESULT_DEFERRED  = "deferred"
+	RESULT_DRY_RUN   = "dry-run"
+)
+
+const (
+	REASON_UNHEALTHY = "found to be unhealthy"
+	REASON_SCHEDULED = "scheduled for restart"
+)
+
+type outcome string
+
+const (
+	OUTCOME_SUCCESS outcome = RESULT_SUCCESS
+	OUTCOME_FAILURE outcome = RESULT_FAILURE
+)
+
 type config struct {
-	DockerSocks        string
-	ContainerLabel     string
-	Interval           time.Duration
-	StartPeriod        time.Duration
-	DefaultStopTimeout string
-	RequestTimeout     time.Duration
-	WebHookUrl         string
-	WebHookKey         string
-	MetricsPort        string
-	MetricsEnabled     string
+	DockerSocks             string
+	DockerHost              string
+	DockerUrl               string
+	TLSVerify               string
+	CertPath                string
+	BaseUrl                 string
+	SSHKey                  string
+	SSHKnownHosts           string
+	ContainerLabel          string
+	Interval                time.Duration
+	StartPeriod             time.Duration
+	DefaultStopTimeout      string
+	RequestTimeout          time.Duration
+	IdleConnTimeout         time.Duration
+	MaxIdleConns            int
+	MaxConcurrentInspects   int
+	RestartTimeoutBuffer    time.Duration
+	CooldownMultiplier      int
+	FlapWindow              time.Duration
+	WebHookUrl              string
+	WebHookKey              string
+	MetricsPort             string
+	DryRun                  bool
+	DryRunNotify            bool
+	HealthPort              string
+	MetricsEnabled          bool
+	MetricsExporter         string
+	MetricsPath             string
+	MetricsUser             string
+	MetricsPass             string
+	OtlpEndpoint            string
+	OtlpProtocol            string
+	OtlpInterval            int
+	OtlpTimeout             int
+	OtlpTracesEndpoint      string
+	OtlpTracesProtocol      string
+	MetricsImageLabel       string
+	MetricsMaxImages        int
+	LogJournal              bool
+	LogFormat               string
+	LogLevel                string
+	MonitorStates           string
+	MaxConcurrent           int
+	IntervalJitter          float64
+	MaxAttempts             int
+	WebHookHeaders          string
+	WebHookRetries          int
+	WebHookMethod           string
+	WebHookQueueSize        int
+	WebHookSecret           string
+	ContainerStartPeriod    time.Duration
+	Mode                    string
+	CaptureLogs             int
+	EventsResync            time.Duration
+	WebHookFormat           string
+	WebHookRetryBackoff     time.Duration
+	AttemptsWindow          time.Duration
+	WebHookTemplate         string
+	WebHookTmplFile         string
+	WebHookDedupWindow      time.Duration
+	WebHookFailureThreshold int
+	WebHookSummary          bool
+	RecheckBeforeRestart    bool
+	LogScan                 bool
+	MetricsScan             bool
+	MetricsScanTimeout      time.Duration
+	SkipDockerManaged       bool
+	ShutdownSentinel        string
+	StatsdAddr              string
+	StatsdPrefix            string
+	EventBrokerUrl          string
+	EventTopic              string
+	K8sEvents               bool
+	K8sEventsOn             sinkFilter
+	K8sEventsFormat         string
+	EventBrokerOn           sinkFilter
+	EventBrokerFormat       string
+	WebHookOn               sinkFilter
+	FlagUrl                 string
+	FlagCacheTTL            time.Duration
+	MaxPerServicePerCycle   int
+	RunAsUser               string
+	RunAsGroup              string
+	VerifyRetries           int
+	VerifyDelay             time.Duration
+	Success                 string
+	RedactLabels            []string
+	HistoryDB               string
+	Debounce                time.Duration
+	RemovalGrace            int
+	CycleDeadline           time.Duration
+	MinAge                  time.Duration
+	MaxAge                  time.Duration
+	Explain                 bool
+	Condition               string
+	LoadThreshold           float64
+	LoadMaxRestarts         int
+	ListRetries             int
+	ListRetryBackoff        time.Duration
+	RestartRetries          int
+	RestartBackoff          time.Duration
+	ControlToken            string
+	Escalation              []string
+	PullTimeout             time.Duration
+	RegistryAuth            string
+	EscalationWindow        time.Duration
+	Urgencies               map[string]urgencyPolicy
+}
+
+type urgencyPolicy struct {
+	Rank     int
+	Cooldown time.Duration
+	Backoff  time.Duration
 }
 
 type Container struct {
-	Id     string            `json:"Id"`
-	Names  []string          `json:"Names"`
-	State  string            `json:"State"`
-	Labels map[string]string `json:"Labels"`
+	Id      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Created int64             `json:"Created"`
+	Labels  map[string]string `json:"Labels"`
+}
+
+func (c Container) Age() time.Duration {
+	return time.Since(time.Unix(c.Created, 0))
+}
+
+func (c Container) Name() string {
+	for _, name := range c.Names {
+		if !strings.Contains(strings.TrimPrefix(name, "/"), "/") {
+			return name
+		}
+	}
+	if len(c.Names) > 0 {
+		return c.Names[0]
+	}
+
+	return ""
+}
+
+type candidate struct {
+	Container Container
+	Reason    string
 }
 
 type Client struct {
-	httpd http.Client
-	httpw http.Client
-	cfg   *config
-	ctr   syncfloat64.Counter
-	ctx   context.Context
+	httpd           http.Client
+	httpw           http.Client
+	cfg             *config
+	ctr             syncfloat64.Counter
+	provider        *metric.MeterProvider
+	tracerProvider  *sdktrace.TracerProvider
+	successes       syncint64.Counter
+	dryRuns         syncint64.Counter
+	recoveries      syncint64.Counter
+	abandoned       syncint64.Counter
+	webhookFailures syncint64.Counter
+	webhookDropped  syncint64.Counter
+	failures        syncint64.Counter
+	remediation     syncfloat64.Histogram
+	inspectLatency  syncfloat64.Histogram
+	restartLatency  syncfloat64.Histogram
+	inspects        chan struct{}
+	ctx             context.Context
+	cancel          context.CancelFunc
+	shutdown        atomic.Bool
+	unhealthy       atomic.Int64
+	docker          dockerAPI
+	rng             *rand.Rand
+	trigger         chan struct{}
+	webhookHeaders  http.Header
+	webhookUrls     []string
+	webhooks        chan []byte
+	webhooksDone    chan struct{}
+	webhooksMu      sync.RWMutex
+	ready           atomic.Bool
+	eventsUp        atomic.Bool
+	scanning        sync.Mutex
+	lastPollOK      bool
+	lastPollTime    time.Time
+	suppressed      atomic.Bool
+	mu              sync.Mutex
+	state           map[string]*containerState
+	seen            map[string]bool
+	hc              map[string]bool
+	history         map[string]*restartHistory
+	logScans        map[string]*logScanState
+	sent            map[[sha256.Size]byte]time.Time
+	tmpl            atomic.Pointer[template.Template]
+	filter          atomic.Pointer[labelFilter]
+	pub             Publisher
+	pubQueue        *sinkQueue
+	statsd          *statsd
+	journal         *journal
+	watchdog        time.Duration
+	k8s             *k8sEvents
+	k8sQueue        *sinkQueue
+	flag            remoteFlag
+	flagMu          sync.Mutex
+	stats           lifetimeStats
+	daemonDown      bool
+	daemonID        string
+	lastListed      int
+	daemonRestarts  syncint64.Counter
+	deferred        syncint64.Counter
+	historyDB       *historyDB
+	pubTmpl         *template.Template
+	k8sTmpl         *template.Template
+	images          map[string]bool
+	conditions      map[string]condition
+	expected        map[string]time.Time
+	starts          syncint64.Counter
+}
+
+type containerState struct {
+	Name              string
+	LastRestart       time.Time
+	Failures          int
+	ProbeFailures     int
+	DetectedAt        time.Time
+	QuorumAlerted     bool
+	DependencyAlerted bool
+	FlagNotified      bool
+	Step              int
+	StepAt            time.Time
+	GaveUp            bool
+	Absent            int
+	AbsentAt          time.Time
 }
 
 func getEnvDuration(name string, defaultVal int) time.Duration {
@@ -72,8 +318,52 @@ func getEnvDuration(name string, defaultVal int) time.Duration {
 	return time.Duration(t) * time.Second
 }
 
+func getEnvFloat(name string, defaultVal float64) float64 {
+	val, err := strconv.ParseFloat(getEnv(name, fmt.Sprint(defaultVal)), 64)
+	if err != nil {
+		return defaultVal
+	}
+
+	return val
+}
+
+func getEnvBool(name string, defaultVal bool) bool {
+	val, err := strconv.ParseBool(getEnv(name, fmt.Sprint(defaultVal)))
+	if err != nil {
+		return defaultVal
+	}
+
+	return val
+}
+
+func getEnvInt(name string, defaultVal int) int {
+	val, err := strconv.Atoi(getEnv(name, fmt.Sprint(defaultVal)))
+	if err != nil {
+		return defaultVal
+	}
+
+	return val
+}
+
+func getEnvFile(name string, defaultVal string) string {
+	path := getEnv(name+"_FILE", "")
+	if path == "" {
+		return getEnv(name, defaultVal)
+	}
+
+	val, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read %s_FILE. %s", name, err)
+	}
+
+	return strings.TrimSpace(string(val))
+}
+
 func getEnv(name string, defaultVal string) string {
 	val := os.Getenv(name)
+	if values := fileValues.Load(); val == "" && values != nil {
+		val = (*values)[name]
+	}
 	if val == "" {
 		return defaultVal
 	}
@@ -81,183 +371,1354 @@ func getEnv(name string, defaultVal string) string {
 	return val
 }
 
+func getUrgencyPolicy(name string, rank int, cooldown int, backoff int) urgencyPolicy {
+	prefix := "AUTOHEAL_URGENCY_" + strings.ToUpper(name)
+
+	return urgencyPolicy{
+		Rank:     rank,
+		Cooldown: getEnvDuration(prefix+"_COOLDOWN", cooldown),
+		Backoff:  getEnvDuration(prefix+"_BACKOFF", backoff),
+	}
+}
+
 func InitConfig() *config {
 	cfg := config{
-		DockerSocks:        getEnv("DOCKER_SOCK", "/var/run/docker.sock"),
-		ContainerLabel:     getEnv("AUTOHEAL_CONTAINER_LABEL", "all"),
-		Interval:           getEnvDuration("AUTOHEAL_INTERVAL", 5),
-		StartPeriod:        getEnvDuration("AUTOHEAL_START_PERIOD", 0),
-		DefaultStopTimeout: getEnv("AUTOHEAL_DEFAULT_STOP_TIMEOUT", "10"),
-		RequestTimeout:     getEnvDuration("CURL_TIMEOUT", 30),
-		WebHookUrl:         getEnv("WEBHOOK_URL", ""),
-		WebHookKey:         getEnv("WEBHOOK_KEY", "text"),
-		MetricsPort:        getEnv("METRICS_PORT", "2333"),
-		MetricsEnabled:     getEnv("METRICS_ENABLED", "true"),
+		DockerSocks:             getEnv("DOCKER_SOCK", "/var/run/docker.sock"),
+		DockerHost:              getEnv("DOCKER_HOST", ""),
+		TLSVerify:               getEnv("DOCKER_TLS_VERIFY", ""),
+		CertPath:                getEnv("DOCKER_CERT_PATH", ""),
+		SSHKey:                  getEnv("DOCKER_SSH_KEY", ""),
+		SSHKnownHosts:           getEnv("DOCKER_SSH_KNOWN_HOSTS", ""),
+		ContainerLabel:          getEnv("AUTOHEAL_CONTAINER_LABEL", "all"),
+		Interval:                getEnvDuration("AUTOHEAL_INTERVAL", 5),
+		StartPeriod:             getEnvDuration("AUTOHEAL_START_PERIOD", 0),
+		DefaultStopTimeout:      getEnv("AUTOHEAL_DEFAULT_STOP_TIMEOUT", "10"),
+		RequestTimeout:          getEnvDuration("CURL_TIMEOUT", 30),
+		IdleConnTimeout:         getEnvDuration("DOCKER_IDLE_CONN_TIMEOUT", 30),
+		MaxIdleConns:            getEnvInt("DOCKER_MAX_IDLE_CONNS", 2),
+		MaxConcurrentInspects:   getEnvInt("DOCKER_MAX_CONCURRENT_INSPECTS", 4),
+		RestartTimeoutBuffer:    getEnvDuration("AUTOHEAL_RESTART_TIMEOUT_BUFFER", 10),
+		CooldownMultiplier:      getEnvInt("AUTOHEAL_COOLDOWN_MULTIPLIER", 2),
+		FlapWindow:              getEnvDuration("AUTOHEAL_FLAP_WINDOW", 300),
+		WebHookUrl:              getEnvFile("WEBHOOK_URL", ""),
+		WebHookKey:              getEnvFile("WEBHOOK_KEY", "text"),
+		MetricsPort:             getEnv("METRICS_PORT", "2333"),
+		DryRun:                  getEnvBool("AUTOHEAL_DRY_RUN", false),
+		DryRunNotify:            getEnvBool("AUTOHEAL_DRY_RUN_NOTIFY", false),
+		HealthPort:              getEnv("HEALTH_PORT", ""),
+		MetricsEnabled:          getEnvBool("METRICS_ENABLED", true),
+		MetricsExporter:         getEnv("OTEL_METRICS_EXPORTER", ""),
+		MetricsPath:             getEnv("METRICS_PATH", "/metrics"),
+		MetricsUser:             getEnv("METRICS_BASIC_AUTH_USER", ""),
+		MetricsPass:             getEnvFile("METRICS_BASIC_AUTH_PASS", ""),
+		OtlpEndpoint:            getEnv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")),
+		OtlpProtocol:            getEnv("OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", getEnv("OTEL_EXPORTER_OTLP_PROTOCOL", OTLP_PROTOCOL)),
+		OtlpInterval:            getEnvInt("OTEL_METRIC_EXPORT_INTERVAL", 60000),
+		OtlpTimeout:             getEnvInt("OTEL_METRIC_EXPORT_TIMEOUT", 30000),
+		OtlpTracesEndpoint:      getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")),
+		OtlpTracesProtocol:      getEnv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", getEnv("OTEL_EXPORTER_OTLP_PROTOCOL", OTLP_PROTOCOL)),
+		MetricsImageLabel:       getEnv("METRICS_IMAGE_LABEL", ""),
+		MetricsMaxImages:        getEnvInt("METRICS_MAX_IMAGES", 100),
+		LogJournal:              getEnvBool("LOG_JOURNAL", false),
+		LogFormat:               getEnv("LOG_FORMAT", "text"),
+		LogLevel:                getEnv("LOG_LEVEL", "info"),
+		MonitorStates:           getEnv("AUTOHEAL_MONITOR_STATES", "unhealthy"),
+		MaxConcurrent:           getEnvInt("AUTOHEAL_MAX_CONCURRENT", 1),
+		IntervalJitter:          math.Min(getEnvFloat("AUTOHEAL_INTERVAL_JITTER", 0), 1),
+		MaxAttempts:             getEnvInt("AUTOHEAL_MAX_ATTEMPTS", 0),
+		WebHookHeaders:          getEnvFile("WEBHOOK_HEADERS", ""),
+		WebHookRetries:          getEnvInt("WEBHOOK_RETRIES", 2),
+		WebHookMethod:           strings.ToUpper(getEnv("WEBHOOK_METHOD", http.MethodPost)),
+		WebHookQueueSize:        getEnvInt("WEBHOOK_QUEUE_SIZE", 100),
+		WebHookSecret:           getEnvFile("WEBHOOK_SIGNING_SECRET", ""),
+		ContainerStartPeriod:    getEnvDuration("AUTOHEAL_CONTAINER_START_PERIOD", 0),
+		Mode:                    getEnv("AUTOHEAL_MODE", MODE_POLL),
+		CaptureLogs:             getEnvInt("AUTOHEAL_CAPTURE_LOGS", 0),
+		EventsResync:            getEnvDuration("AUTOHEAL_EVENTS_RESYNC", 60),
+		WebHookFormat:           getEnv("WEBHOOK_FORMAT", WEBHOOK_FORMAT_TEXT),
+		WebHookRetryBackoff:     time.Duration(getEnvInt("WEBHOOK_RETRY_BACKOFF_MS", 500)) * time.Millisecond,
+		AttemptsWindow:          getEnvDuration("AUTOHEAL_ATTEMPTS_WINDOW", 3600),
+		WebHookTemplate:         getEnv("WEBHOOK_TEMPLATE", ""),
+		WebHookTmplFile:         getEnv("WEBHOOK_TEMPLATE_FILE", ""),
+		WebHookDedupWindow:      getEnvDuration("WEBHOOK_DEDUP_WINDOW", 0),
+		WebHookFailureThreshold: getEnvInt("WEBHOOK_FAILURE_THRESHOLD", 0),
+		WebHookSummary:          getEnvBool("WEBHOOK_SUMMARY", false),
+		RecheckBeforeRestart:    getEnvBool("AUTOHEAL_RECHECK_BEFORE_RESTART", false),
+		LogScan:                 getEnvBool("AUTOHEAL_LOG_SCAN", false),
+		MetricsScan:             getEnvBool("AUTOHEAL_METRICS_SCAN", false),
+		MetricsScanTimeout:      getEnvDuration("AUTOHEAL_METRICS_SCAN_TIMEOUT", 5),
+		SkipDockerManaged:       getEnvBool("AUTOHEAL_SKIP_DOCKER_MANAGED", false),
+		ShutdownSentinel:        getEnv("AUTOHEAL_SHUTDOWN_SENTINEL", "/run/systemd/shutdown/scheduled"),
+		StatsdAddr:              getEnv("STATSD_ADDR", ""),
+		StatsdPrefix:            getEnv("STATSD_PREFIX", "docker_restart"),
+		EventBrokerUrl:          getEnv("EVENT_BROKER_URL", ""),
+		EventTopic:              getEnv("EVENT_TOPIC", "autoheal.restarts"),
+		K8sEvents:               getEnvBool("K8S_EVENTS", false),
+		K8sEventsOn:             parseSinkFilter(getEnv("K8S_EVENTS_NOTIFY_ON", "success,failure")),
+		K8sEventsFormat:         getEnv("K8S_EVENTS_FORMAT", ""),
+		EventBrokerOn:           parseSinkFilter(getEnv("EVENT_BROKER_NOTIFY_ON", "success,failure")),
+		EventBrokerFormat:       getEnv("EVENT_BROKER_FORMAT", ""),
+		WebHookOn:               parseSinkFilter(getEnv("WEBHOOK_NOTIFY_ON", "all")),
+		FlagUrl:                 getEnv("AUTOHEAL_FLAG_URL", ""),
+		FlagCacheTTL:            getEnvDuration("AUTOHEAL_FLAG_CACHE_TTL", 30),
+		MaxPerServicePerCycle:   getEnvInt("AUTOHEAL_MAX_PER_SERVICE_PER_CYCLE", 0),
+		RunAsUser:               getEnv("AUTOHEAL_USER", ""),
+		RunAsGroup:              getEnv("AUTOHEAL_GROUP", ""),
+		VerifyRetries:           getEnvInt("AUTOHEAL_VERIFY_RETRIES", 5),
+		VerifyDelay:             getEnvDuration("AUTOHEAL_VERIFY_DELAY", 2),
+		Success:                 getEnv("AUTOHEAL_SUCCESS", ""),
+		RedactLabels:            parseRedactLabels(getEnv("AUTOHEAL_REDACT_LABELS", "")),
+		HistoryDB:               getEnv("HISTORY_DB", ""),
+		Debounce:                getEnvDuration("AUTOHEAL_DEBOUNCE", 0),
+		RemovalGrace:            getEnvInt("AUTOHEAL_REMOVAL_GRACE", 1),
+		CycleDeadline:           getEnvDuration("AUTOHEAL_CYCLE_DEADLINE", 0),
+		MinAge:                  getEnvDuration("AUTOHEAL_MIN_AGE", 0),
+		MaxAge:                  getEnvDuration("AUTOHEAL_MAX_AGE", 0),
+		Explain:                 getEnvBool("AUTOHEAL_EXPLAIN", false),
+		Condition:               getEnv("AUTOHEAL_CONDITION", ""),
+		LoadThreshold:           getEnvFloat("AUTOHEAL_LOAD_THRESHOLD", 0),
+		LoadMaxRestarts:         getEnvInt("AUTOHEAL_LOAD_MAX_RESTARTS", 1),
+		ListRetries:             getEnvInt("DOCKER_LIST_RETRIES", 2),
+		ListRetryBackoff:        time.Duration(getEnvInt("DOCKER_LIST_RETRY_BACKOFF_MS", 200)) * time.Millisecond,
+		RestartRetries:          getEnvInt("AUTOHEAL_RESTART_RETRIES", 0),
+		RestartBackoff:          getEnvDuration("AUTOHEAL_RESTART_BACKOFF", 1),
+		ControlToken:            getEnv("CONTROL_TOKEN", ""),
+		Escalation:              parseEscalation(getEnv("AUTOHEAL_ESCALATION", ACTION_RESTART)),
+		EscalationWindow:        getEnvDuration("AUTOHEAL_ESCALATION_WINDOW", 0),
+		PullTimeout:             getEnvDuration("AUTOHEAL_PULL_TIMEOUT", 600),
+		RegistryAuth:            getEnvFile("REGISTRY_AUTH", ""),
+		Urgencies: map[string]urgencyPolicy{
+			URGENCY_HIGH:   getUrgencyPolicy(URGENCY_HIGH, 0, 0, 10),
+			URGENCY_NORMAL: getUrgencyPolicy(URGENCY_NORMAL, 1, getEnvInt("AUTOHEAL_COOLDOWN", 0), 30),
+			URGENCY_LOW:    getUrgencyPolicy(URGENCY_LOW, 2, 300, 60),
+		},
+	}
+	cfg.DockerUrl = "http://unix"
+	switch {
+	case strings.HasPrefix(cfg.DockerHost, "tcp://") && cfg.TLSVerify != "":
+		cfg.DockerUrl = "https://" + strings.TrimSuffix(strings.TrimPrefix(cfg.DockerHost, "tcp://"), "/")
+	case strings.HasPrefix(cfg.DockerHost, "tcp://"):
+		cfg.DockerUrl = "http://" + strings.TrimSuffix(strings.TrimPrefix(cfg.DockerHost, "tcp://"), "/")
+	case strings.HasPrefix(cfg.DockerHost, "unix://"):
+		cfg.DockerSocks = strings.TrimPrefix(cfg.DockerHost, "unix://")
 	}
+	cfg.BaseUrl = cfg.DockerUrl + "/containers/"
 
 	return &cfg
 }
 
+func dockerDialer(c *config) func(context.Context, string, string) (net.Conn, error) {
+	if strings.HasPrefix(c.DockerHost, "ssh://") {
+		d, err := newSSHDialer(c)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return d.DialContext
+	}
+
+	if strings.HasPrefix(c.DockerHost, "tcp://") {
+		d := &net.Dialer{Timeout: c.RequestTimeout}
+		return d.DialContext
+	}
+
+	return func(_ context.Context, _, _ string) (net.Conn, error) {
+		return net.Dial(UNIX, c.DockerSocks)
+	}
+}
+
 func NewClient() *Client {
 	c := InitConfig()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	tlsConfig, err := dockerTLS(c)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	return &Client{
+	client := &Client{
 		cfg: c,
 		httpd: http.Client{
 			Timeout: c.RequestTimeout,
 			Transport: &http.Transport{
-				DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-					return net.Dial(UNIX, c.DockerSocks)
-				},
+				DialContext:         dockerDialer(c),
+				TLSClientConfig:     tlsConfig,
+				IdleConnTimeout:     c.IdleConnTimeout,
+				MaxIdleConns:        c.MaxIdleConns,
+				MaxIdleConnsPerHost: c.MaxIdleConns,
 			},
 		},
 		httpw: http.Client{
 			Timeout: c.RequestTimeout,
 		},
-		ctx: context.TODO(),
+		ctx:  ctx,
+		flag: remoteFlag{Enabled: true},
+		stats: lifetimeStats{
+			Started:     time.Now(),
+			Results:     map[string]int{},
+			ByContainer: map[string]int{},
+		},
+		cancel:     cancel,
+		state:      map[string]*containerState{},
+		seen:       map[string]bool{},
+		hc:         map[string]bool{},
+		history:    map[string]*restartHistory{},
+		logScans:   map[string]*logScanState{},
+		sent:       map[[sha256.Size]byte]time.Time{},
+		inspects:   newSemaphore(c.MaxConcurrentInspects),
+		images:     map[string]bool{},
+		conditions: map[string]condition{},
+		expected:   map[string]time.Time{},
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		trigger:    make(chan struct{}, 1),
 	}
+	client.docker = daemonAPI{c: client}
+
+	return client
 }
 
 func main() {
+	validate := flag.Bool("validate-config", false, "validate the AUTOHEAL_CONFIG_FILE configuration and exit")
+	flag.Parse()
+
+	if path := os.Getenv("AUTOHEAL_CONFIG_FILE"); path != "" {
+		values, err := loadConfigFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fileValues.Store(&values)
+		if *validate {
+			infof("%s is valid.\n", path)
+			os.Exit(0)
+		}
+	} else if *validate {
+		log.Fatal("AUTOHEAL_CONFIG_FILE is not set, nothing to validate")
+	}
+
 	client := NewClient()
+	if client.cfg.Explain {
+		if err := client.explain(); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
 	client.init()
 
-	for {
-		containers, err := client.getContainers()
-		if err != nil {
-			fmt.Printf("Failed to list containers. %s\n", err)
-		} else {
-			for _, c := range containers {
-				t := time.Now().Format(TIME_FORMAT)
-				id := c.Id[0:12]
-
-				if len(c.Names) == 0 || c.Names[0] == NULL {
-					fmt.Printf("%s Container name of (%s) is null, which implies container does not exist - don't restart.\n", t, id)
-					continue
-				}
-
-				if c.State == RESTARTING {
-					fmt.Printf("%s Container %s (%s) found to be restarting - don't restart.\n", t, c.Names[0], id)
-					continue
-				}
-
-				fmt.Printf("%s Container %s (%s) found to be unhealthy - Restarting container now.\n", t, c.Names[0], id)
-				client.restart(c, id, t)
-			}
+	for client.ctx.Err() == nil {
+		if client.hostShuttingDown() {
+			client.pingWatchdog()
+			client.delay()
+			continue
 		}
+
+		client.runOnce(client.ctx)
 		client.delay()
 	}
+
+	client.reportSummary()
+	client.drainWebhooks()
+	client.pubQueue.drain()
+	client.k8sQueue.drain()
+	client.shutdownMetrics()
+	client.shutdownTracing()
 }
 
-func (c *Client) restart(container Container, id string, t string) {
-	if err := c.restartContainer(container.Id, container.Labels["autoheal.stop.timeout"]); err != nil {
-		c.addMetric(container.Names[0], "Failed to restart the container")
-		if err := c.notify("%s Container %s (%s) found to be unhealthy. Failed to restart the container.\n", t, container.Names[0], id); err != nil {
-			fmt.Printf("Failed to call webhook. %s\n", err)
+func (c *Client) runOnce(ctx context.Context) (cycleSummary, error) {
+	c.scanning.Lock()
+	defer c.scanning.Unlock()
+
+	ctx, span := tracer.Start(ctx, "runOnce")
+	defer span.End()
+	cycle, cancel := c.cycleContext(ctx)
+	defer cancel()
+
+	summary := cycleSummary{Restarted: []string{}, Failed: []string{}}
+	var err error
+	defer func() {
+		traceResult(span, err,
+			attribute.Int("containers.found", summary.Found),
+			attribute.Int("containers.restarted", len(summary.Restarted)),
+			attribute.Int("containers.failed", len(summary.Failed)),
+		)
+	}()
+
+	f := c.filter.Load()
+	c.warnUnmonitored(f)
+	c.pruneHistory()
+
+	var containers []Container
+	var candidates []candidate
+	containers, err = c.getContainers(cycle, f)
+	c.recordPoll(err)
+	stable := c.daemonStable(containers, err)
+	if err != nil {
+		errorf("Failed to list containers. %s\n", err)
+	} else {
+		if c.cfg.Mode != MODE_EVENTS {
+			c.pingWatchdog()
+		}
+		c.unhealthy.Store(int64(len(containers)))
+		summary.Found = len(containers)
+		if stable {
+			c.reportRecovered(c.pruneState(containers))
+		}
+		c.sortByAge(containers)
+		c.sortByUrgency(containers)
+		for _, container := range containers {
+			if c.conditionOf(container) == "" {
+				candidates = append(candidates, candidate{Container: container, Reason: REASON_UNHEALTHY})
+			}
 		}
+	}
+	if cycle.Err() == nil {
+		candidates = append(candidates, c.scanLogs(f)...)
+		candidates = append(candidates, c.scanMetrics(f)...)
+		candidates = append(candidates, c.scanConditions(f)...)
+	}
+
+	summary.Restarted, summary.Failed = c.remediate(cycle, candidates)
+
+	return summary, err
+}
+
+// scanEach runs check on every container concurrently, so one slow container
+// does not hold up the rest of the cycle, and keeps the hits in list order.
+func scanEach(containers []Container, check func(Container) *candidate) []candidate {
+	found := make([]*candidate, len(containers))
+	var wg sync.WaitGroup
+	for i, container := range containers {
+		wg.Add(1)
+		go func(i int, container Container) {
+			defer wg.Done()
+			found[i] = check(container)
+		}(i, container)
+	}
+	wg.Wait()
+
+	var hits []candidate
+	for _, hit := range found {
+		if hit != nil {
+			hits = append(hits, *hit)
+		}
+	}
+
+	return hits
+}
+
+func (c *Client) remediate(ctx context.Context, candidates []candidate) ([]string, []string) {
+	restarted, failed := []string{}, []string{}
+	if len(candidates) == 0 {
+		return restarted, failed
+	}
+
+	var mu sync.Mutex
+	pool := make(chan struct{}, max(c.cfg.MaxConcurrent, 1))
+	var workers sync.WaitGroup
+
+	services := map[string]int{}
+	budget := c.loadBudget()
+	seen := map[string]bool{}
+	for i, cand := range candidates {
+		if c.hostShuttingDown() {
+			break
+		}
+
+		if ctx.Err() != nil {
+			warnf("%s Cycle deadline of %s exceeded - Deferring %d container(s) to the next cycle.\n", time.Now().Format(TIME_FORMAT), c.cfg.CycleDeadline, len(candidates)-i)
+			break
+		}
+
+		container := cand.Container
+		if seen[container.Id] {
+			continue
+		}
+		seen[container.Id] = true
+
+		t := time.Now().Format(TIME_FORMAT)
+		id := shortID(container.Id)
+
+		action, ok := c.admit(container, cand.Reason, services, &budget, id, t)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case c.cfg.DryRun:
+		case action != ACTION_RESTART:
+			containerLog(container.Name(), id).infof("%s Container %s (%s) %s - Escalating to %s now.\n", t, container.Name(), id, cand.Reason, action)
+		case cand.Reason == REASON_UNHEALTHY:
+			containerLog(container.Name(), id).infof("%s Container %s (%s) found to be unhealthy (%s) - Restarting container now.\n", t, container.Name(), id, container.Status)
+		default:
+			containerLog(container.Name(), id).infof("%s Container %s (%s) %s - Restarting container now.\n", t, container.Name(), id, cand.Reason)
+		}
+		pool <- struct{}{}
+		workers.Add(1)
+		go func(container Container, id string, t string, reason string, action string) {
+			defer func() {
+				<-pool
+				workers.Done()
+			}()
+			if reason == REASON_UNHEALTHY && !c.recheck(container, id, t) {
+				return
+			}
+			result := c.act(trace.ContextWithSpan(c.ctx, trace.SpanFromContext(ctx)), container, id, t, reason, action)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if result == RESULT_FAILURE {
+				failed = append(failed, container.Name())
+			} else if result == RESULT_SUCCESS {
+				restarted = append(restarted, container.Name())
+			}
+		}(container, id, t, cand.Reason, action)
+	}
+	workers.Wait()
+
+	return restarted, failed
+}
+
+func (c *Client) admit(container Container, reason string, services map[string]int, budget *int, id string, t string) (string, bool) {
+	if container.Name() == "" || container.Name() == NULL {
+		debugf("%s Container name of (%s) is null, which implies container does not exist - don't restart.\n", t, id)
+		return "", false
+	}
+
+	if container.State == RESTARTING {
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) found to be restarting - don't restart.\n", t, container.Name(), id)
+		return "", false
+	}
+
+	if age := container.Age(); age < c.cfg.MinAge || (c.cfg.MaxAge > 0 && age > c.cfg.MaxAge) {
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) %s but was created %s ago, outside the age window - don't restart.\n", t, container.Name(), id, reason, age.Round(time.Second))
+		return "", false
+	}
+
+	if left := c.startGraceLeft(container); left > 0 {
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) %s but is still in its start period - Grace ends in %s.\n", t, container.Name(), id, reason, left.Round(time.Second))
+		return "", false
+	}
+
+	unhealthy := reason == REASON_UNHEALTHY
+	if unhealthy {
+		if d := c.unhealthyFor(container.Id); d < c.cfg.Debounce {
+			containerLog(container.Name(), id).debugf("%s Container %s (%s) found to be unhealthy for %s - Waiting for it to stay unhealthy for %s.\n", t, container.Name(), id, d.Round(time.Second), c.cfg.Debounce)
+			return "", false
+		}
+	}
+
+	if wait := c.restartWait(container); wait > 0 {
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) %s - Waiting %s before restarting (%s urgency).\n", t, container.Name(), id, reason, wait.Round(time.Second), c.urgency(container))
+		return "", false
+	}
+
+	if !c.withinDailyLimit(container, id, t) {
+		return "", false
+	}
+
+	if reason != REASON_SCHEDULED && !c.withinAttempts(container, id, t) {
+		return "", false
+	}
+
+	if !c.quorumAllows(container, id, t) {
+		return "", false
+	}
+
+	if !c.dependencyUp(container, id, t) {
+		return "", false
+	}
+
+	if unhealthy {
+		if confirmed, failures, threshold := c.confirmUnhealthy(container); !confirmed {
+			if failures == 0 {
+				containerLog(container.Name(), id).debugf("%s Container %s (%s) found to be unhealthy but its probe succeeded - don't restart.\n", t, container.Name(), id)
+			} else {
+				containerLog(container.Name(), id).debugf("%s Container %s (%s) found to be unhealthy - Probe failed %d/%d times, don't restart yet.\n", t, container.Name(), id, failures, threshold)
+			}
+			return "", false
+		}
+	}
+
+	if c.cfg.SkipDockerManaged {
+		inspect, err := c.inspectContainer(container.Id)
+		if err == nil && inspect.ManagedByDocker() {
+			containerLog(container.Name(), id).infof("%s Container %s (%s) is already being restarted by its %s restart policy (%d restarts) - don't restart.\n", t, container.Name(), id, inspect.HostConfig.RestartPolicy.Name, inspect.RestartCount)
+			return "", false
+		}
+	}
+
+	if !c.restartsEnabled() {
+		c.notifyOnly(container, id, t, reason)
+		return "", false
+	}
+
+	if c.serviceThrottled(container, services, id, t) {
+		return "", false
+	}
+
+	if c.loadThrottled(container, budget, id, t) {
+		return "", false
+	}
+
+	if !unhealthy {
+		return ACTION_RESTART, true
+	}
+
+	action, ok := c.nextAction(container)
+	if !ok {
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) found to be unhealthy - Waiting for the %s step to take effect.\n", t, container.Name(), id, action)
+	}
+
+	return action, ok
+}
+
+// recheck re-inspects the container right before acting on it, once the
+// worker pool had room, so a container that recovered meanwhile is left alone.
+func (c *Client) recheck(container Container, id string, t string) bool {
+	if !c.cfg.RecheckBeforeRestart {
+		return true
+	}
+
+	still, err := c.stillUnhealthy(container.Id)
+	if err != nil {
+		containerLog(container.Name(), id).warnf("%s Container %s (%s) could not be re-inspected - don't restart. %s\n", t, container.Name(), id, err)
+		return false
+	}
+	if !still {
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) recovered before restart - don't restart.\n", t, container.Name(), id)
+		return false
+	}
+
+	return true
+}
+
+func (c *Client) restart(container Container, id string, t string, reason string) {
+	c.act(c.ctx, container, id, t, reason, ACTION_RESTART)
+}
+
+func (c *Client) act(ctx context.Context, container Container, id string, t string, reason string, action string) string {
+	ctx, span := tracer.Start(ctx, "restart", trace.WithAttributes(
+		attribute.String("container.id", id),
+		attribute.String("container.name", container.Name()),
+		attribute.String("reason", reason),
+		attribute.String("action", action),
+	))
+	defer span.End()
+
+	if c.cfg.DryRun {
+		c.dryRun(container, id, t, reason, action)
+		traceResult(span, nil, attribute.String("outcome", RESULT_DRY_RUN))
+		return RESULT_DRY_RUN
+	}
+
+	if action == ACTION_NOTIFY {
+		c.giveUp(container, id, t)
+		traceResult(span, nil, attribute.String("outcome", RESULT_ESCALATED))
+		return RESULT_ESCALATED
+	}
+
+	if !c.restartsEnabled() {
+		c.notifyOnly(container, id, t, reason)
+		traceResult(span, nil, attribute.String("outcome", RESULT_BLOCKED))
+		return RESULT_BLOCKED
+	}
+
+	logs := c.captureLogs(container, id, t)
+
+	image := ""
+	if action != ACTION_KILL && container.Labels["autoheal.update"] == "true" {
+		image = c.updatedImage(container, id, t)
+	}
+
+	start := time.Now()
+	c.expectStart(container.Id)
+	var err error
+	verb, done := "restart", "restarted"
+	switch {
+	case action == ACTION_KILL:
+		verb, done = "kill and start", "killed and started"
+		err = c.docker.kill(container.Id)
+	case action == ACTION_RECREATE || image != "":
+		verb, done = "recreate", "recreated"
+		if image != "" {
+			verb, done = "update and recreate", "updated and recreated"
+		}
+		var newId string
+		if newId, err = c.docker.recreate(container.Id, image); err == nil {
+			c.moveState(container.Id, newId)
+			container.Id = newId
+		}
+	case container.Labels["autoheal.kill.signal"] != "":
+		err = c.killRestartContainer(container.Id, container.Labels["autoheal.kill.signal"], container.Labels["autoheal.kill.after"])
+	default:
+		err = c.retryRestart(ctx, container, id)
+	}
+	elapsed := time.Since(start)
+
+	e := Event{Time: t, Container: container.Name(), Id: id, Reason: reason, Result: RESULT_SUCCESS, Message: "Successfully " + done + " the container", EventId: newEventId(), Status: container.Status, Logs: logs}
+	if err != nil {
+		e.Result = RESULT_FAILURE
+		e.Message = "Failed to " + verb + " the container"
+	} else if err = c.verify(container); err != nil {
+		e.Result = RESULT_FAILURE
+		e.Message = fmt.Sprintf("Restarted the container but it failed verification (%s)", err)
+	}
+	e.Failures = c.recordRestart(container.Id, reason, err == nil)
+	if err != nil {
+		c.remediationFailed(container.Id)
+	}
+	traceResult(span, err, attribute.String("outcome", e.Result))
+
+	c.addMetric(e.Container, e.Message, outcome(e.Result), id, e.EventId)
+	c.addImageMetric(container.Image, e.Result)
+	record := historyRecord{Time: start, Container: e.Container, Image: container.Image, Action: action, Result: e.Result, Duration: elapsed, EventId: e.EventId}
+	if image != "" {
+		record.Image, record.Action = image, ACTION_UPDATE
+	}
+	if err := c.historyDB.Record(record); err != nil {
+		errorf("Failed to record restart history. %s\n", err)
+	}
+	c.countRestart(e.Container, e.Result)
+	tags := map[string]string{"container": e.Container, "result": e.Result}
+	c.statsd.Count("restarts", tags)
+	c.statsd.Timing("restart_duration", elapsed, tags)
+	if err := c.notify(e); err != nil {
+		errorf("Failed to call webhook. %s\n", err)
+	}
+
+	return e.Result
+}
+
+func (c *Client) urgency(container Container) string {
+	u := strings.ToLower(container.Labels["autoheal.urgency"])
+	if _, ok := c.cfg.Urgencies[u]; ok {
+		return u
+	}
+
+	return URGENCY_NORMAL
+}
+
+func (c *Client) sortByUrgency(containers []Container) {
+	sort.SliceStable(containers, func(i, j int) bool {
+		return c.cfg.Urgencies[c.urgency(containers[i])].Rank < c.cfg.Urgencies[c.urgency(containers[j])].Rank
+	})
+}
+
+func (c *Client) restartWait(container Container) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	policy := c.cfg.Urgencies[c.urgency(container)]
+
+	if s, ok := c.state[container.Id]; ok && s.Failures > 0 {
+		shift := s.Failures - 1
+		if shift > 10 {
+			shift = 10
+		}
+		return time.Until(s.LastRestart.Add(policy.Backoff << shift))
+	}
+
+	h, ok := c.history[container.Id]
+	if !ok || len(h.Restarts) == 0 {
+		return 0
+	}
+
+	return time.Until(h.Restarts[len(h.Restarts)-1].Add(c.scaledCooldown(policy.Cooldown, h.Streak)))
+}
+
+func (c *Client) startGraceLeft(container Container) time.Duration {
+	grace := c.cfg.ContainerStartPeriod
+	if s, err := strconv.Atoi(container.Labels["autoheal.start.period"]); err == nil {
+		grace = time.Duration(s) * time.Second
+	}
+	if grace <= 0 {
+		return 0
+	}
+
+	inspect, err := c.inspectContainer(container.Id)
+	if err != nil || !inspect.State.Running {
+		return 0
+	}
+	started, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
+	if err != nil {
+		return 0
+	}
+
+	return time.Until(started.Add(grace))
+}
+
+func (c *Client) unhealthyFor(id string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.state[id]
+	if !ok || s.DetectedAt.IsZero() {
+		return 0
+	}
+
+	return time.Since(s.DetectedAt)
+}
+
+func (c *Client) stateFor(id string) *containerState {
+	s, ok := c.state[id]
+	if !ok {
+		s = &containerState{}
+		c.state[id] = s
+	}
+
+	return s
+}
+
+func (c *Client) recordRestart(id string, reason string, ok bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen[id] = true
+	// Scheduled restarts are planned, not remediation, so they don't count
+	// towards the attempt and daily limits.
+	if reason != REASON_SCHEDULED {
+		c.recordHistory(id)
+	}
+
+	s := c.stateFor(id)
+	s.LastRestart = time.Now()
+	s.ProbeFailures = 0
+	s.QuorumAlerted = false
+	s.FlagNotified = false
+	if ok {
+		s.Failures = 0
 	} else {
-		c.addMetric(container.Names[0], "Successfully restarted the container")
-		if err := c.notify("%s Container %s (%s) found to be unhealthy. Successfully restarted the container.\n", t, container.Names[0], id); err != nil {
-			fmt.Printf("Failed to call webhook. %s\n", err)
+		s.Failures++
+	}
+
+	return s.Failures
+}
+
+func (c *Client) pruneState(containers []Container) map[string]string {
+	seen := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		seen[container.Id] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, container := range containers {
+		s := c.stateFor(container.Id)
+		if s.DetectedAt.IsZero() {
+			s.DetectedAt = now
+		}
+		s.Name = container.Name()
+		s.Absent = 0
+	}
+
+	gone := map[string]string{}
+
+	for id, s := range c.state {
+		if seen[id] {
+			continue
 		}
+		if s.Absent == 0 {
+			s.AbsentAt = now
+		}
+		if s.Absent++; s.Absent < c.cfg.RemovalGrace {
+			continue
+		}
+		if !s.LastRestart.IsZero() {
+			c.recordRemediation(RESULT_RECOVERED, s.AbsentAt.Sub(s.DetectedAt))
+		}
+		if s.Name != "" {
+			gone[id] = s.Name
+		}
+		delete(c.state, id)
+	}
+
+	for id, h := range c.history {
+		if seen[id] {
+			h.LastUnhealthy = now
+		}
+	}
+
+	return gone
+}
+
+func (c *Client) remediationFailed(id string) {
+	c.mu.Lock()
+	s, ok := c.state[id]
+	var detected time.Time
+	if ok {
+		detected = s.DetectedAt
+	}
+	c.mu.Unlock()
+
+	if !detected.IsZero() {
+		c.recordRemediation(RESULT_FAILED, time.Since(detected))
+	}
+}
+
+func (c *Client) recordRemediation(result string, d time.Duration) {
+	if c.cfg.MetricsEnabled {
+		c.remediation.Record(c.ctx, d.Seconds(), attribute.String("result", result))
 	}
 }
 
-func (c *Client) addMetric(key string, value string) {
-	if c.cfg.MetricsEnabled == "true" {
+func (c *Client) addMetric(key string, value string, result outcome, id string, eventId string) {
+	if c.cfg.MetricsEnabled {
 		c.ctr.Add(c.ctx, 1, []attribute.KeyValue{
 			attribute.Key(key).String(value),
 		}...)
+		counter := c.successes
+		if result == OUTCOME_FAILURE {
+			counter = c.failures
+		}
+		counter.Add(c.ctx, 1, attribute.String("container", key))
+		addExemplar(key, string(result), id, eventId)
+	}
+}
+
+func (c *Client) metricsMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle(c.cfg.MetricsPath, c.metricsAuth(metricsHandler()))
+	if c.cfg.ControlToken != "" {
+		mux.HandleFunc("/config", c.controlHandler(c.handleConfig))
+		mux.HandleFunc("/scan", c.controlHandler(c.handleScan))
 	}
+	mux.HandleFunc("/version", c.handleVersion)
+	if c.cfg.HealthPort == "" || c.cfg.HealthPort == c.cfg.MetricsPort {
+		mux.HandleFunc("/healthz", c.handleHealthz)
+	}
+
+	return mux
 }
 
-func (c *Client) serveMetrics() {
-	fmt.Printf("%s Serving metrics at : %s /metrics\n", time.Now().Format(TIME_FORMAT), c.cfg.MetricsPort)
-	http.Handle("/metrics", promhttp.Handler())
-	err := http.ListenAndServe(":"+c.cfg.MetricsPort, nil)
+func (c *Client) serveMetrics(l net.Listener) {
+	infof("%s Serving metrics at : %s %s\n", time.Now().Format(TIME_FORMAT), c.cfg.MetricsPort, c.cfg.MetricsPath)
+	err := http.Serve(l, c.metricsMux())
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
 func (c *Client) init() {
-	if c.cfg.MetricsEnabled == "true" {
-		exporter, err := prometheus.New()
+	level, err := parseLevel(c.cfg.LogLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logLevel.Set(level)
+
+	if c.cfg.LogFormat == "json" {
+		logger = newLogger(c.cfg.LogFormat)
+		logOutput = slogWriter{logger: logger}
+	}
+
+	if c.cfg.LogJournal {
+		j, err := NewJournal()
+		if err != nil {
+			warnf("Journal socket not available, logging to stdout. %s\n", err)
+		} else {
+			c.journal = j
+			logOutput = j
+		}
+	}
+
+	if c.cfg.HistoryDB != "" {
+		db, err := openHistoryDB(c.cfg.HistoryDB)
 		if err != nil {
 			log.Fatal(err)
 		}
-		provider := metric.NewMeterProvider(metric.WithReader(exporter))
-		meter := provider.Meter("docker_restart")
+		c.historyDB = db
+	}
 
-		ctr, err := meter.SyncFloat64().Counter("containers_restarts", instrument.WithDescription("Total number of containers restart."))
+	if err := c.loadTemplate(); err != nil {
+		log.Fatal(err)
+	}
+	if c.webhookHeaders, err = parseHeaders(c.cfg.WebHookHeaders); err != nil {
+		log.Fatal(err)
+	}
+	switch c.cfg.WebHookFormat {
+	case WEBHOOK_FORMAT_TEXT, WEBHOOK_FORMAT_SLACK, WEBHOOK_FORMAT_DISCORD, WEBHOOK_FORMAT_JSON:
+	default:
+		log.Fatalf("Unknown WEBHOOK_FORMAT %q, expected text, slack, discord or json", c.cfg.WebHookFormat)
+	}
+	for _, target := range strings.Split(c.cfg.WebHookUrl, ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			c.webhookUrls = append(c.webhookUrls, target)
+		}
+	}
+	if c.cfg.WebHookUrl != "" && c.cfg.WebHookQueueSize > 0 {
+		c.startWebhooks()
+	}
+	f, err := newLabelFilter(c.cfg.ContainerLabel, c.cfg.MonitorStates)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.filter.Store(f)
+	go c.watchReload()
+	go c.watchShutdown()
+
+	if c.cfg.StatsdAddr != "" {
+		s, err := NewStatsd(c.cfg.StatsdAddr, c.cfg.StatsdPrefix)
+		if err != nil {
+			errorf("Failed to set up statsd, continuing without it. %s\n", err)
+		} else {
+			c.statsd = s
+		}
+	}
+
+	if c.cfg.K8sEvents {
+		k, err := NewK8sEvents(c.cfg.RequestTimeout)
 		if err != nil {
 			log.Fatal(err)
 		}
-		c.ctr = ctr
-		c.ctr.Add(c.ctx, 0, []attribute.KeyValue{}...)
+		c.k8s = k
 
-		go c.serveMetrics()
+		if c.k8sTmpl, err = parseFormat("K8S_EVENTS_FORMAT", c.cfg.K8sEventsFormat); err != nil {
+			log.Fatal(err)
+		}
+		c.k8sQueue = newSinkQueue("create kubernetes event", func(e Event) error {
+			return c.k8s.Emit(e, c.k8sTmpl)
+		})
 	}
 
-	fmt.Printf("Monitoring containers for unhealthy status in %s\n", c.cfg.StartPeriod)
-	time.Sleep(c.cfg.StartPeriod)
-}
+	if c.cfg.EventBrokerUrl != "" {
+		pub, err := NewPublisher(c.cfg.EventBrokerUrl, c.cfg.RequestTimeout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.pub = pub
 
-func (c *Client) delay() {
-	time.Sleep(c.cfg.Interval)
-}
+		if c.pubTmpl, err = parseFormat("EVENT_BROKER_FORMAT", c.cfg.EventBrokerFormat); err != nil {
+			log.Fatal(err)
+		}
+		c.pubQueue = newSinkQueue("publish event", c.publish)
+	}
 
-func (c *Client) notify(format string, a ...any) error {
-	fmt.Printf(format, a...)
+	if err := c.startTracing(); err != nil {
+		log.Fatal(err)
+	}
 
-	if c.cfg.WebHookUrl != "" {
-		body, err := json.Marshal(map[string]string{c.cfg.WebHookKey: fmt.Sprintf(format, a...)})
+	if c.cfg.MetricsEnabled {
+		readers, err := c.metricReaders()
 		if err != nil {
-			return err
+			log.Fatal(err)
+		}
+		c.initMeters(readers)
+		go c.watchEvents()
+
+		prometheusRegister(restartEvents, buildInfo)
+		buildInfo.WithLabelValues(version, commit, buildDate).Set(1)
+		if c.cfg.MetricsImageLabel != "" {
+			prometheusRegister(imageRestarts)
 		}
 
-		_, err = c.httpw.Post(c.cfg.WebHookUrl, CONTENT_TYPE, bytes.NewBuffer(body))
+		if !strings.HasPrefix(c.cfg.MetricsPath, "/") {
+			log.Fatalf("METRICS_PATH %q must start with /", c.cfg.MetricsPath)
+		}
+		l, err := net.Listen("tcp", ":"+c.cfg.MetricsPort)
 		if err != nil {
-			return err
+			log.Fatal(err)
 		}
+		go c.serveMetrics(l)
 	}
 
-	return nil
+	if c.cfg.HealthPort != "" && (c.cfg.HealthPort != c.cfg.MetricsPort || !c.cfg.MetricsEnabled) {
+		l, err := net.Listen("tcp", ":"+c.cfg.HealthPort)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go c.serveHealth(l)
+	}
+
+	if err := c.dropPrivileges(); err != nil {
+		log.Fatal(err)
+	}
+	go c.watchSchedules()
+
+	switch c.cfg.Mode {
+	case MODE_POLL:
+	case MODE_EVENTS:
+		go c.watchUnhealthy()
+	default:
+		log.Fatalf("Unknown AUTOHEAL_MODE %q, expected poll or events", c.cfg.Mode)
+	}
+
+	started := time.Now()
+	c.waitForDaemon()
+
+	remaining := c.cfg.StartPeriod - time.Since(started)
+	if remaining < 0 {
+		remaining = 0
+	}
+	infof("Monitoring containers for unhealthy status in %s\n", remaining.Round(time.Second))
+	time.Sleep(remaining)
+	c.ready.Store(true)
+	c.notifyReady()
+}
+
+func (c *Client) initMeters(readers []metric.Reader) {
+	opts := []metric.Option{}
+	for _, reader := range readers {
+		opts = append(opts, metric.WithReader(reader))
+	}
+	c.provider = metric.NewMeterProvider(append(opts,
+		metric.WithView(metric.NewView(
+			metric.Instrument{Name: "remediation_duration_seconds"},
+			metric.Stream{Aggregation: aggregation.ExplicitBucketHistogram{Boundaries: []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}}},
+		)),
+		metric.WithView(metric.NewView(
+			metric.Instrument{Name: "docker_inspect_duration_seconds"},
+			metric.Stream{Aggregation: aggregation.ExplicitBucketHistogram{Boundaries: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}}},
+		)),
+		metric.WithView(metric.NewView(
+			metric.Instrument{Name: "restart_duration_seconds"},
+			metric.Stream{Aggregation: aggregation.ExplicitBucketHistogram{Boundaries: durationBuckets(c.restartDeadline())}},
+		)),
+	)...)
+	meter := c.provider.Meter("docker_restart")
+
+	ctr, err := meter.SyncFloat64().Counter("containers_restarts", instrument.WithDescription("Total number of containers restart."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.ctr = ctr
+	c.ctr.Add(c.ctx, 0, []attribute.KeyValue{}...)
+
+	successes, err := meter.SyncInt64().Counter("restarts_success", instrument.WithDescription("Number of successful restarts, by container."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.successes = successes
+
+	failures, err := meter.SyncInt64().Counter("restarts_failure", instrument.WithDescription("Number of failed restarts, by container."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.failures = failures
+
+	dryRuns, err := meter.SyncInt64().Counter("restarts_skipped_dryrun", instrument.WithDescription("Number of restarts skipped because of dry-run, by container."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.dryRuns = dryRuns
+
+	recoveries, err := meter.SyncInt64().Counter("recovery", instrument.WithDescription("Number of unhealthy containers seen recovering."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.recoveries = recoveries
+
+	abandoned, err := meter.SyncInt64().Counter("restarts_abandoned", instrument.WithDescription("Number of containers given up on after AUTOHEAL_MAX_ATTEMPTS restarts, by container."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.abandoned = abandoned
+
+	webhookFailures, err := meter.SyncInt64().Counter("webhook_failures", instrument.WithDescription("Number of webhook notifications lost after all retries."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.webhookFailures = webhookFailures
+
+	webhookDropped, err := meter.SyncInt64().Counter("webhook_dropped", instrument.WithDescription("Number of webhook notifications dropped because the queue was full."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.webhookDropped = webhookDropped
+
+	remediation, err := meter.SyncFloat64().Histogram("remediation_duration_seconds", instrument.WithDescription("Time from detecting a container unhealthy until it recovered or remediation failed."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.remediation = remediation
+
+	inspectLatency, err := meter.SyncFloat64().Histogram("docker_inspect_duration_seconds", instrument.WithDescription("Latency of container inspect calls to the Docker daemon."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.inspectLatency = inspectLatency
+
+	restartLatency, err := meter.SyncFloat64().Histogram("restart_duration_seconds", instrument.WithDescription("Latency of container restart calls to the Docker daemon."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.restartLatency = restartLatency
+
+	daemonRestarts, err := meter.SyncInt64().Counter("docker_daemon_restarts", instrument.WithDescription("Number of times the Docker daemon came back with a different daemon ID."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.daemonRestarts = daemonRestarts
+
+	deferred, err := meter.SyncInt64().Counter("restarts_deferred", instrument.WithDescription("Number of restarts deferred, by reason."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.deferred = deferred
+
+	starts, err := meter.SyncInt64().Counter("container_starts", instrument.WithDescription("Container starts seen on the Docker events stream, by whether autoheal caused them."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.starts = starts
+
+	unique, err := meter.AsyncInt64().Gauge("unique_containers_restarted", instrument.WithDescription("Number of distinct containers restarted since startup."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = meter.RegisterCallback([]instrument.Asynchronous{unique}, func(ctx context.Context) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		unique.Observe(ctx, int64(len(c.seen)))
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	unhealthy, err := meter.AsyncInt64().Gauge("containers_unhealthy", instrument.WithDescription("Number of unhealthy containers seen in the last cycle."))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = meter.RegisterCallback([]instrument.Asynchronous{unhealthy}, func(ctx context.Context) {
+		unhealthy.Observe(ctx, c.unhealthy.Load(), attribute.String("filter", c.filter.Load().Label))
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (c *Client) cycleContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if c.cfg.CycleDeadline > 0 {
+		return context.WithTimeout(parent, c.cfg.CycleDeadline)
+	}
+
+	return context.WithCancel(parent)
+}
+
+func (c *Client) interval() time.Duration {
+	if c.cfg.IntervalJitter <= 0 {
+		return c.cfg.Interval
+	}
+
+	spread := c.cfg.IntervalJitter * (2*c.rng.Float64() - 1)
+	return c.cfg.Interval + time.Duration(spread*float64(c.cfg.Interval))
 }
 
-func (c *Client) restartContainer(id string, timeout string) error {
+func (c *Client) delay() {
+	if c.cfg.Mode == MODE_EVENTS {
+		select {
+		case <-c.trigger:
+		case <-time.After(c.cfg.EventsResync):
+		case <-c.ctx.Done():
+		}
+		return
+	}
+
+	select {
+	case <-time.After(c.interval()):
+	case <-c.ctx.Done():
+	}
+}
+
+func (c *Client) restartContainer(ctx context.Context, id string, timeout string, signal string) (err error) {
+	ctx, span := tracer.Start(ctx, "restartContainer", trace.WithAttributes(attribute.String("container.id", shortID(id))))
+	defer func() {
+		traceResult(span, err)
+		span.End()
+	}()
+
 	t := c.cfg.DefaultStopTimeout
 	if timeout != "" {
 		t = timeout
 	}
-	_, err := c.httpd.PostForm(BASE_URL+id+COMMAND+t, url.Values{})
+	target := c.cfg.BaseUrl + id + COMMAND + t
+	if signal != "" {
+		target += "&signal=" + url.QueryEscape(signal)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	response, err := c.restartClient(t).Do(request)
+	if c.cfg.MetricsEnabled {
+		c.restartLatency.Record(ctx, time.Since(start).Seconds())
+	}
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("restart returned status %d: %s", response.StatusCode, bytes.TrimSpace(body))
+	}
+	io.Copy(io.Discard, response.Body)
+
+	return nil
+}
+
+func (c *Client) retryRestart(ctx context.Context, container Container, id string) error {
+	err := c.docker.restart(ctx, container.Id, container.Labels["autoheal.stop.timeout"], container.Labels["autoheal.stop.signal"])
+
+	backoff := c.cfg.RestartBackoff
+	attempt := 1
+	defer func() {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int("restart.attempts", attempt))
+	}()
+	for ; err != nil && attempt <= c.cfg.RestartRetries; attempt++ {
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/5+1))
+		containerLog(container.Name(), id).warnf("%s Failed to restart container %s (%s), retrying in %s (%d/%d). %s\n", time.Now().Format(TIME_FORMAT), container.Name(), id, wait.Round(time.Millisecond), attempt, c.cfg.RestartRetries, err)
+
+		select {
+		case <-time.After(wait):
+		case <-c.ctx.Done():
+			return err
+		}
+
+		err = c.docker.restart(ctx, container.Id, container.Labels["autoheal.stop.timeout"], container.Labels["autoheal.stop.signal"])
+		backoff *= 2
+	}
+
 	return err
 }
 
-func (c *Client) getContainers() ([]Container, error) {
-	qs := map[string][]string{"health": []string{"unhealthy"}}
-	if c.cfg.ContainerLabel != "all" {
-		qs["label"] = []string{c.cfg.ContainerLabel + "=true"}
+func (c *Client) restartDeadline() time.Duration {
+	deadline := c.cfg.RequestTimeout
+	if s, err := strconv.Atoi(c.cfg.DefaultStopTimeout); err == nil {
+		deadline = max(deadline, time.Duration(s)*time.Second+c.cfg.RestartTimeoutBuffer)
+	}
+
+	return deadline
+}
+
+func (c *Client) restartClient(stopTimeout string) *http.Client {
+	s, err := strconv.Atoi(stopTimeout)
+	if err != nil {
+		return &c.httpd
+	}
+
+	deadline := time.Duration(s)*time.Second + c.cfg.RestartTimeoutBuffer
+	if deadline <= c.httpd.Timeout {
+		return &c.httpd
+	}
+
+	client := c.httpd
+	client.Timeout = deadline
+	return &client
+}
+
+func (c *Client) queryFiltered(ctx context.Context, f *labelFilter) ([]Container, error) {
+	var all []Container
+	for _, query := range f.Queries {
+		containers, err := c.queryContainers(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, containers...)
+	}
+
+	return all, nil
+}
+
+func (c *Client) getContainers(ctx context.Context, f *labelFilter) ([]Container, error) {
+	containers, err := c.docker.listUnhealthy(ctx, f)
+
+	backoff := c.cfg.ListRetryBackoff
+	for attempt := 1; err != nil && attempt <= c.cfg.ListRetries; attempt++ {
+		warnf("Failed to list containers, retrying in %s (%d/%d). %s\n", backoff, attempt, c.cfg.ListRetries, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			return nil, err
+		}
+
+		containers, err = c.docker.listUnhealthy(ctx, f)
+		backoff *= 2
+	}
+
+	return enabledContainers(uniqueContainers(containers)), err
+}
+
+func disabled(container Container) bool {
+	return container.Labels["autoheal.disable"] == "true"
+}
+
+func enabledContainers(containers []Container) []Container {
+	enabled := containers[:0]
+	for _, container := range containers {
+		if disabled(container) {
+			containerLog(container.Name(), shortID(container.Id)).debugf("%s Container %s (%s) has autoheal.disable=true - ignoring.\n", time.Now().Format(TIME_FORMAT), container.Name(), shortID(container.Id))
+			continue
+		}
+		enabled = append(enabled, container)
+	}
+
+	return enabled
+}
+
+func uniqueContainers(containers []Container) []Container {
+	seen := make(map[string]bool, len(containers))
+	unique := containers[:0]
+	for _, container := range containers {
+		if seen[container.Id] {
+			continue
+		}
+		seen[container.Id] = true
+		unique = append(unique, container)
 	}
+
+	return unique
+}
+
+func (c *Client) listFiltered(f *labelFilter, qs map[string][]string) ([]Container, error) {
+	var all []Container
+	for _, q := range f.scope(qs) {
+		containers, err := c.listContainers(q)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, containers...)
+	}
+
+	return uniqueContainers(all), nil
+}
+
+func (c *Client) listContainers(qs map[string][]string) ([]Container, error) {
 	query, err := json.Marshal(qs)
 
 	if err != nil {
 		return nil, err
 	}
 
-	response, err := c.httpd.Get(BASE_URL + FILTER + string(query[:]))
+	return c.docker.list(c.ctx, string(query[:]))
+}
+
+func (c *Client) queryContainers(ctx context.Context, query string) ([]Container, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.BaseUrl+FILTER+query, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.httpd.Do(request)
 	if err != nil {
 		return nil, err
 	}
+	defer response.Body.Close()
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		return nil, err
 	}
 
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, dockerError(response.Request.URL.Path, response.StatusCode, body)
+	}
+
 	var containers []Container
 	err = json.Unmarshal(body, &containers)
 	if err != nil {
+		if message := daemonMessage(body); message != "" {
+			return nil, fmt.Errorf("docker daemon error: %s", message)
+		}
 		return nil, err
 	}
 	return containers, nil
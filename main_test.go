@@ -0,0 +1,621 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func captureLog(t *testing.T) *syncBuffer {
+	t.Helper()
+	out := &syncBuffer{}
+	old := logOutput
+	logOutput = out
+	t.Cleanup(func() { logOutput = old })
+
+	return out
+}
+
+func newTestClient(t *testing.T, env map[string]string) *Client {
+	t.Helper()
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	c := NewClient()
+	t.Cleanup(c.cancel)
+
+	return c
+}
+
+type fakeAPI struct {
+	mu        sync.Mutex
+	listed    func(query string) []Container
+	unhealthy []Container
+	inspects  map[string]*ContainerInspect
+	output    string
+	err       error
+	calls     []string
+	restarted func(id string)
+	memory    memoryStats
+	failures  int
+}
+
+func (d *fakeAPI) record(call string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, call)
+}
+
+func (d *fakeAPI) count(verb string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := 0
+	for _, call := range d.calls {
+		if strings.HasPrefix(call, verb+" ") {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (d *fakeAPI) listUnhealthy(ctx context.Context, f *labelFilter) ([]Container, error) {
+	return d.unhealthy, nil
+}
+
+func (d *fakeAPI) list(ctx context.Context, query string) ([]Container, error) {
+	if d.listed == nil {
+		return nil, nil
+	}
+
+	return d.listed(query), nil
+}
+
+func (d *fakeAPI) inspect(id string) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	inspect, ok := d.inspects[id]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", id)
+	}
+
+	return json.Marshal(inspect)
+}
+
+func (d *fakeAPI) restart(ctx context.Context, id string, timeout string, signal string) error {
+	d.record("restart " + id)
+	if d.restarted != nil {
+		d.restarted(id)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("daemon busy")
+	}
+	return d.err
+}
+
+func (d *fakeAPI) kill(id string) error {
+	d.record("kill " + id)
+	return d.err
+}
+
+func (d *fakeAPI) signal(id string, signal string) error {
+	d.record("signal " + id + " " + signal)
+	return d.err
+}
+
+func (d *fakeAPI) stop(id string, timeout string) error {
+	d.record("stop " + id)
+	return d.err
+}
+
+func (d *fakeAPI) start(id string) error {
+	d.record("start " + id)
+	return d.err
+}
+
+func (d *fakeAPI) recreate(id string, image string) (string, error) {
+	d.record("recreate " + id)
+	return id + "-new", d.err
+}
+
+func (d *fakeAPI) logs(id string, query string) (string, error) {
+	return d.output, nil
+}
+
+func (d *fakeAPI) stats(id string) ([]byte, error) {
+	return json.Marshal(map[string]memoryStats{"memory_stats": d.memory})
+}
+
+func newFakeClient(t *testing.T, d *fakeAPI, env map[string]string) *Client {
+	t.Helper()
+	if _, ok := env["METRICS_ENABLED"]; !ok {
+		env["METRICS_ENABLED"] = "false"
+	}
+	c := newTestClient(t, env)
+	c.docker = d
+
+	f, err := newLabelFilter(c.cfg.ContainerLabel, c.cfg.MonitorStates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.filter.Store(f)
+
+	return c
+}
+
+func TestContainerName(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"/web"}, "/web"},
+		{[]string{"/proxy/web", "/web", "/db/web"}, "/web"},
+		{[]string{"/proxy/web", "/db/web"}, "/proxy/web"},
+	}
+
+	for _, tt := range tests {
+		if got := (Container{Names: tt.names}).Name(); got != tt.want {
+			t.Errorf("Name() of %v = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestRunOnceShortId(t *testing.T) {
+	out := captureLog(t)
+	d := &fakeAPI{unhealthy: []Container{{Id: "abcd", Names: []string{"/web"}, State: "running"}}}
+	c := newFakeClient(t, d, map[string]string{})
+
+	if _, err := c.runOnce(c.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count("restart"); n != 1 {
+		t.Fatalf("restarted %d container(s), want 1", n)
+	}
+	if !strings.Contains(out.String(), "Container /web (abcd)") {
+		t.Errorf("log does not carry the full short id:\n%s", out.String())
+	}
+}
+
+type countingBody struct {
+	io.Reader
+	closed *atomic.Int32
+}
+
+func (b countingBody) Close() error {
+	b.closed.Add(1)
+	return nil
+}
+
+type countingTransport struct {
+	opened, closed atomic.Int32
+}
+
+func (rt *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.opened.Add(1)
+	body := countingBody{Reader: strings.NewReader(`[{"Id": "0123456789abcdef", "Names": ["/web"]}]`), closed: &rt.closed}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: body, Request: r, Header: http.Header{}}, nil
+}
+
+func TestGetContainersClosesBodies(t *testing.T) {
+	c := newTestClient(t, map[string]string{"METRICS_ENABLED": "false"})
+	rt := &countingTransport{}
+	c.httpd.Transport = rt
+
+	f, err := newLabelFilter(c.cfg.ContainerLabel, c.cfg.MonitorStates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := c.getContainers(c.ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if opened, closed := rt.opened.Load(), rt.closed.Load(); opened == 0 || closed != opened {
+		t.Errorf("closed %d of %d response bodies", closed, opened)
+	}
+}
+
+func TestDockerHost(t *testing.T) {
+	tests := []struct {
+		host, tlsVerify string
+		url, sock       string
+	}{
+		{"", "", "http://unix", "/var/run/docker.sock"},
+		{"unix:///run/user/1000/docker.sock", "", "http://unix", "/run/user/1000/docker.sock"},
+		{"tcp://10.0.0.5:2375", "", "http://10.0.0.5:2375", "/var/run/docker.sock"},
+		{"tcp://10.0.0.5:2375/", "", "http://10.0.0.5:2375", "/var/run/docker.sock"},
+		{"tcp://docker.internal:2376", "1", "https://docker.internal:2376", "/var/run/docker.sock"},
+	}
+
+	t.Setenv("DOCKER_SOCK", "")
+	for _, tt := range tests {
+		t.Setenv("DOCKER_HOST", tt.host)
+		t.Setenv("DOCKER_TLS_VERIFY", tt.tlsVerify)
+
+		cfg := InitConfig()
+		if cfg.DockerUrl != tt.url || cfg.DockerSocks != tt.sock {
+			t.Errorf("DOCKER_HOST=%q gave url %q sock %q, want %q %q", tt.host, cfg.DockerUrl, cfg.DockerSocks, tt.url, tt.sock)
+		}
+		if want := tt.url + "/containers/"; cfg.BaseUrl != want {
+			t.Errorf("DOCKER_HOST=%q gave base url %q, want %q", tt.host, cfg.BaseUrl, want)
+		}
+	}
+}
+
+func TestDockerHostDial(t *testing.T) {
+	ping := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_ping" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "OK")
+	})
+
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen(UNIX, sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unix := httptest.NewUnstartedServer(ping)
+	unix.Listener = l
+	unix.Start()
+	defer unix.Close()
+
+	tcp := httptest.NewServer(ping)
+	defer tcp.Close()
+
+	for _, host := range []string{"unix://" + sock, "tcp://" + strings.TrimPrefix(tcp.URL, "http://")} {
+		c := newTestClient(t, map[string]string{"DOCKER_HOST": host})
+		if err := c.ping(); err != nil {
+			t.Errorf("ping over DOCKER_HOST=%s failed: %s", host, err)
+		}
+	}
+}
+
+func TestGetEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhook_url")
+	if err := os.WriteFile(path, []byte("https://hooks.example.com/secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("WEBHOOK_URL", "https://hooks.example.com/plain")
+	if got := getEnvFile("WEBHOOK_URL", ""); got != "https://hooks.example.com/plain" {
+		t.Errorf("getEnvFile without _FILE = %q", got)
+	}
+
+	t.Setenv("WEBHOOK_URL_FILE", path)
+	if got := getEnvFile("WEBHOOK_URL", ""); got != "https://hooks.example.com/secret" {
+		t.Errorf("getEnvFile with _FILE = %q", got)
+	}
+}
+
+func TestGetEnvFileMissing(t *testing.T) {
+	if os.Getenv("AUTOHEAL_TEST_FATAL") == "1" {
+		getEnvFile("WEBHOOK_URL", "")
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestGetEnvFileMissing$")
+	cmd.Env = append(os.Environ(), "AUTOHEAL_TEST_FATAL=1", "WEBHOOK_URL_FILE="+filepath.Join(t.TempDir(), "missing"))
+	out, err := cmd.CombinedOutput()
+
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.Success() {
+		t.Fatalf("getEnvFile with a missing file did not exit: %v", err)
+	}
+	if !strings.Contains(string(out), "Failed to read WEBHOOK_URL_FILE") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestIntervalJitter(t *testing.T) {
+	tests := []struct {
+		jitter   string
+		min, max time.Duration
+	}{
+		{"0", 10 * time.Second, 10 * time.Second},
+		{"0.2", 8 * time.Second, 12 * time.Second},
+		{"1", 0, 20 * time.Second},
+		{"5", 0, 20 * time.Second},
+	}
+
+	for _, tt := range tests {
+		c := newTestClient(t, map[string]string{"AUTOHEAL_INTERVAL": "10", "AUTOHEAL_INTERVAL_JITTER": tt.jitter})
+		c.rng = rand.New(rand.NewSource(1))
+
+		spread := map[time.Duration]bool{}
+		for i := 0; i < 1000; i++ {
+			d := c.interval()
+			if d < tt.min || d > tt.max {
+				t.Fatalf("AUTOHEAL_INTERVAL_JITTER=%s gave %s, outside %s-%s", tt.jitter, d, tt.min, tt.max)
+			}
+			spread[d] = true
+		}
+		if tt.min != tt.max && len(spread) < 2 {
+			t.Errorf("AUTOHEAL_INTERVAL_JITTER=%s never varied the interval", tt.jitter)
+		}
+	}
+}
+
+func TestCooldownIsScaled(t *testing.T) {
+	c := newTestClient(t, map[string]string{"AUTOHEAL_COOLDOWN": "60", "AUTOHEAL_COOLDOWN_MULTIPLIER": "2"})
+
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}}
+	c.history[container.Id] = &restartHistory{Restarts: []time.Time{time.Now()}, Streak: 3}
+
+	if wait := c.restartWait(container); wait <= 3*time.Minute || wait > 4*time.Minute {
+		t.Errorf("restartWait after a streak of 3 = %s, want AUTOHEAL_COOLDOWN scaled to 4m", wait)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	container := Container{Id: "0123456789abcdef", Names: []string{"/web"}}
+
+	for _, tt := range []struct {
+		cooldown string
+		wait     bool
+	}{
+		{"", false},
+		{"0", false},
+		{"60", true},
+	} {
+		c := newTestClient(t, map[string]string{"AUTOHEAL_COOLDOWN": tt.cooldown})
+		c.history[container.Id] = &restartHistory{Restarts: []time.Time{time.Now()}, Streak: 1}
+
+		if wait := c.restartWait(container); (wait > 0) != tt.wait {
+			t.Errorf("AUTOHEAL_COOLDOWN=%q: restartWait right after a restart = %s", tt.cooldown, wait)
+		}
+	}
+}
+
+func TestBoolFlags(t *testing.T) {
+	for _, value := range []string{"true", "TRUE", "1", "t"} {
+		t.Setenv("AUTOHEAL_LOG_SCAN", value)
+		t.Setenv("AUTOHEAL_SKIP_DOCKER_MANAGED", value)
+		if cfg := InitConfig(); !cfg.LogScan || !cfg.SkipDockerManaged {
+			t.Errorf("%q was not read as true", value)
+		}
+	}
+
+	t.Setenv("AUTOHEAL_LOG_SCAN", "0")
+	if InitConfig().LogScan {
+		t.Error(`"0" was read as true`)
+	}
+}
+
+func TestUrgencyDefaults(t *testing.T) {
+	u := InitConfig().Urgencies
+	high, normal, low := u[URGENCY_HIGH], u[URGENCY_NORMAL], u[URGENCY_LOW]
+
+	if !(high.Cooldown <= normal.Cooldown && normal.Cooldown < low.Cooldown) {
+		t.Errorf("cooldowns high %s, normal %s, low %s are not increasing", high.Cooldown, normal.Cooldown, low.Cooldown)
+	}
+	if !(high.Backoff < normal.Backoff && normal.Backoff < low.Backoff) {
+		t.Errorf("backoffs high %s, normal %s, low %s are not strictly increasing", high.Backoff, normal.Backoff, low.Backoff)
+	}
+}
+
+func TestRecheckRunsInWorker(t *testing.T) {
+	health := func(status string) *ContainerInspect {
+		inspect := &ContainerInspect{}
+		inspect.State.Running = true
+		inspect.State.Health = &ContainerHealth{Status: status}
+		return inspect
+	}
+
+	web := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}
+	db := Container{Id: "fedcba9876543210", Names: []string{"/db"}, State: "running"}
+	d := &fakeAPI{
+		unhealthy: []Container{web, db},
+		inspects:  map[string]*ContainerInspect{web.Id: health("unhealthy"), db.Id: health("unhealthy")},
+	}
+	d.restarted = func(id string) {
+		if id == web.Id {
+			d.mu.Lock()
+			d.inspects[db.Id] = health("healthy")
+			d.mu.Unlock()
+		}
+	}
+	c := newFakeClient(t, d, map[string]string{"AUTOHEAL_RECHECK_BEFORE_RESTART": "true", "AUTOHEAL_MAX_CONCURRENT": "1"})
+
+	if _, err := c.runOnce(c.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if d.count("restart") != 1 || d.calls[0] != "restart "+web.Id {
+		t.Errorf("docker calls = %v, want only %s restarted after %s recovered while waiting for the pool", d.calls, web.Id, db.Id)
+	}
+}
+
+func TestKillRestartContainer(t *testing.T) {
+	const id = "0123456789abcdef"
+
+	t.Run("exited", func(t *testing.T) {
+		d := &fakeAPI{inspects: map[string]*ContainerInspect{id: {}}}
+		c := newFakeClient(t, d, map[string]string{})
+
+		if err := c.killRestartContainer(id, "SIGQUIT", "5"); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"signal " + id + " SIGQUIT", "stop " + id, "start " + id}
+		if strings.Join(d.calls, ",") != strings.Join(want, ",") {
+			t.Errorf("docker calls = %v, want %v", d.calls, want)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		running := &ContainerInspect{}
+		running.State.Running = true
+		d := &fakeAPI{inspects: map[string]*ContainerInspect{id: running}}
+		c := newFakeClient(t, d, map[string]string{})
+		c.cancel()
+
+		start := time.Now()
+		if err := c.killRestartContainer(id, "SIGQUIT", "30"); err == nil {
+			t.Fatal("kill restart succeeded after shutdown")
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Errorf("kill restart waited %s after shutdown", time.Since(start))
+		}
+		if d.count("start") != 0 {
+			t.Errorf("docker calls = %v, want no start after shutdown", d.calls)
+		}
+	})
+}
+
+func TestRestartRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  string
+		failures int
+		calls    int
+		result   string
+	}{
+		{"recovers", "2", 2, 3, RESULT_SUCCESS},
+		{"exhausted", "1", 2, 2, RESULT_FAILURE},
+		{"disabled", "0", 1, 1, RESULT_FAILURE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}
+			d := &fakeAPI{failures: tt.failures}
+			c := newFakeClient(t, d, map[string]string{"AUTOHEAL_RESTART_RETRIES": tt.retries})
+			c.cfg.RestartBackoff = time.Millisecond
+
+			if got := c.act(c.ctx, container, shortID(container.Id), "", REASON_UNHEALTHY, ACTION_RESTART); got != tt.result {
+				t.Errorf("act = %s, want %s", got, tt.result)
+			}
+			if n := d.count("restart"); n != tt.calls {
+				t.Errorf("restart attempts = %d, want %d", n, tt.calls)
+			}
+			if got := c.stats.Results; len(got) != 1 || got[tt.result] != 1 {
+				t.Errorf("recorded outcomes %v, want only one %s", got, tt.result)
+			}
+		})
+	}
+}
+
+func TestDuplicateContainersRestartedOnce(t *testing.T) {
+	web := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}
+	db := Container{Id: "fedcba9876543210", Names: []string{"/db"}, State: "running"}
+	d := &fakeAPI{unhealthy: []Container{web, db, web}}
+	c := newFakeClient(t, d, map[string]string{})
+
+	if _, err := c.runOnce(c.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"restart " + web.Id, "restart " + db.Id}; strings.Join(d.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("docker calls = %v, want each container restarted once", d.calls)
+	}
+}
+
+func TestDisabledContainersIgnored(t *testing.T) {
+	tests := []struct {
+		name  string
+		label string
+		extra map[string]string
+	}{
+		{"all", "all", nil},
+		{"label filter", "autoheal", map[string]string{"autoheal": "true"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := func(disable string) map[string]string {
+				l := map[string]string{"autoheal.disable": disable}
+				for k, v := range tt.extra {
+					l[k] = v
+				}
+				return l
+			}
+			web := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running", Labels: labels("true")}
+			db := Container{Id: "fedcba9876543210", Names: []string{"/db"}, State: "running", Labels: labels("false")}
+			d := &fakeAPI{unhealthy: []Container{web, db}}
+			c := newFakeClient(t, d, map[string]string{"AUTOHEAL_CONTAINER_LABEL": tt.label})
+
+			if _, err := c.runOnce(c.ctx); err != nil {
+				t.Fatal(err)
+			}
+			if len(d.calls) != 1 || d.calls[0] != "restart "+db.Id {
+				t.Errorf("docker calls = %v, want only %s restarted", d.calls, db.Names[0])
+			}
+		})
+	}
+}
+
+func TestConcurrentRestarts(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	tests := []struct {
+		concurrent string
+		min, max   time.Duration
+	}{
+		{"1", 4 * delay, 8 * delay},
+		{"4", delay, 3 * delay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.concurrent, func(t *testing.T) {
+			var containers []Container
+			for i := 0; i < 4; i++ {
+				containers = append(containers, Container{Id: fmt.Sprintf("%016x", i+1), Names: []string{fmt.Sprintf("/web-%d", i)}, State: "running"})
+			}
+			d := &fakeAPI{unhealthy: containers, restarted: func(string) { time.Sleep(delay) }}
+			c := newFakeClient(t, d, map[string]string{"AUTOHEAL_MAX_CONCURRENT": tt.concurrent})
+
+			start := time.Now()
+			summary, err := c.runOnce(c.ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			elapsed := time.Since(start)
+
+			if len(summary.Restarted) != 4 {
+				t.Errorf("restarted %v, want all 4 containers", summary.Restarted)
+			}
+			if elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("4 restarts with AUTOHEAL_MAX_CONCURRENT=%s took %s, want between %s and %s", tt.concurrent, elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const UNBOUNDED_RESTART_DEADLINE = 5 * time.Minute
+
+var restartEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "containers_restart_events_total",
+	Help: "Total number of containers restart, with the container and event ids attached as an exemplar.",
+}, []string{"container", "result"})
+
+var imageRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "image_restarts_total",
+	Help: "Total number of containers restart, by image.",
+}, []string{"image", "result"})
+
+func imageLabel(image string, mode string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if mode == "repository" {
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			image = image[:i]
+		}
+	}
+
+	return image
+}
+
+func (c *Client) addImageMetric(image string, result string) {
+	if !c.cfg.MetricsEnabled || c.cfg.MetricsImageLabel == "" {
+		return
+	}
+
+	image = imageLabel(image, c.cfg.MetricsImageLabel)
+
+	c.mu.Lock()
+	if _, ok := c.images[image]; !ok {
+		if len(c.images) >= c.cfg.MetricsMaxImages {
+			image = "other"
+		} else {
+			c.images[image] = true
+		}
+	}
+	c.mu.Unlock()
+
+	imageRestarts.WithLabelValues(image, result).Inc()
+}
+
+func prometheusRegister(cs ...prometheus.Collector) {
+	prometheus.MustRegister(cs...)
+}
+
+func metricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	}))
+}
+
+func addExemplar(container string, result string, id string, eventId string) {
+	ctr := restartEvents.WithLabelValues(container, result)
+	if adder, ok := ctr.(prometheus.ExemplarAdder); ok {
+		adder.AddWithExemplar(1, prometheus.Labels{"container_id": id, "event_id": eventId})
+		return
+	}
+	ctr.Inc()
+}
+
+func durationBuckets(limit time.Duration) []float64 {
+	if limit <= 0 {
+		limit = UNBOUNDED_RESTART_DEADLINE
+	}
+
+	buckets := []float64{}
+	for scale := 0.1; ; scale *= 10 {
+		for _, b := range []float64{1, 2.5, 5} {
+			if b*scale >= limit.Seconds() {
+				return append(buckets, limit.Seconds())
+			}
+			buckets = append(buckets, b*scale)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func newMeteredClient(t *testing.T, env map[string]string) (*Client, metric.Reader) {
+	t.Helper()
+	env["METRICS_ENABLED"] = "true"
+	c := newTestClient(t, env)
+	f, err := newLabelFilter(c.cfg.ContainerLabel, c.cfg.MonitorStates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.filter.Store(f)
+
+	reader := metric.NewManualReader()
+	c.initMeters([]metric.Reader{reader})
+
+	return c, reader
+}
+
+func histogram(t *testing.T, reader metric.Reader, name string) []metricdata.HistogramDataPoint {
+	t.Helper()
+	rm, err := reader.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram); ok && m.Name == name {
+				return h.DataPoints
+			}
+		}
+	}
+
+	return nil
+}
+
+func counters(t *testing.T, reader metric.Reader, name string) map[string]int64 {
+	t.Helper()
+	rm, err := reader.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if s, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == name {
+				for _, p := range s.DataPoints {
+					v, _ := p.Attributes.Value("container")
+					got[v.AsString()] += p.Value
+				}
+			}
+		}
+	}
+
+	return got
+}
+
+func TestRestartOutcomeCounters(t *testing.T) {
+	web := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}
+	db := Container{Id: "fedcba9876543210", Names: []string{"/db"}, State: "running"}
+
+	t.Run("enabled", func(t *testing.T) {
+		c, reader := newMeteredClient(t, map[string]string{})
+		d := &fakeAPI{}
+		c.docker = d
+
+		c.act(c.ctx, web, shortID(web.Id), "", REASON_UNHEALTHY, ACTION_RESTART)
+		c.act(c.ctx, web, shortID(web.Id), "", REASON_UNHEALTHY, ACTION_RESTART)
+		d.err = errors.New("conflict")
+		c.act(c.ctx, db, shortID(db.Id), "", REASON_UNHEALTHY, ACTION_RESTART)
+
+		if got := counters(t, reader, "restarts_success"); len(got) != 1 || got["/web"] != 2 {
+			t.Errorf("restarts_success = %v, want 2 for /web", got)
+		}
+		if got := counters(t, reader, "restarts_failure"); len(got) != 1 || got["/db"] != 1 {
+			t.Errorf("restarts_failure = %v, want 1 for /db", got)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newFakeClient(t, &fakeAPI{err: errors.New("conflict")}, map[string]string{"METRICS_ENABLED": "false"})
+		if got := c.act(c.ctx, db, shortID(db.Id), "", REASON_UNHEALTHY, ACTION_RESTART); got != RESULT_FAILURE {
+			t.Errorf("act = %s, want %s without metrics", got, RESULT_FAILURE)
+		}
+	})
+}
+
+func TestRestartDurationRecorded(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer daemon.Close()
+
+	c, reader := newMeteredClient(t, map[string]string{"DOCKER_HOST": "tcp://" + strings.TrimPrefix(daemon.URL, "http://")})
+	if err := c.restartContainer(c.ctx, "0123456789abcdef", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	points := histogram(t, reader, "restart_duration_seconds")
+	if len(points) != 1 || points[0].Count != 1 {
+		t.Fatalf("restart_duration_seconds = %+v, want one observation", points)
+	}
+}
+
+func TestFailedRemediationRecorded(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		err     error
+		history bool
+	}{
+		{"failed restart", map[string]string{}, errors.New("conflict"), false},
+		{"abandoned", map[string]string{"AUTOHEAL_MAX_ATTEMPTS": "1"}, nil, true},
+		{"gave up", map[string]string{"AUTOHEAL_ESCALATION": "notify"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, reader := newMeteredClient(t, tt.env)
+			container := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}
+			c.docker = &fakeAPI{unhealthy: []Container{container}, err: tt.err}
+			if tt.history {
+				c.history[container.Id] = &restartHistory{Restarts: []time.Time{time.Now().Add(-time.Minute)}}
+			}
+
+			if _, err := c.runOnce(c.ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			points := histogram(t, reader, "remediation_duration_seconds")
+			if len(points) != 1 || points[0].Count != 1 {
+				t.Fatalf("remediation_duration_seconds = %+v, want one observation", points)
+			}
+			if v, _ := points[0].Attributes.Value("result"); v.AsString() != RESULT_FAILED {
+				t.Errorf("observation result = %q, want %q", v.AsString(), RESULT_FAILED)
+			}
+		})
+	}
+}
+
+func TestRestartDurationBuckets(t *testing.T) {
+	tests := []struct {
+		timeout, stop, buffer string
+		last                  float64
+	}{
+		{"30", "10", "10", 30},
+		{"5", "10", "10", 20},
+		{"0", "10", "10", 20},
+		{"0", "", "10", UNBOUNDED_RESTART_DEADLINE.Seconds()},
+	}
+
+	for _, tt := range tests {
+		c := newTestClient(t, map[string]string{"CURL_TIMEOUT": tt.timeout, "AUTOHEAL_DEFAULT_STOP_TIMEOUT": tt.stop, "AUTOHEAL_RESTART_TIMEOUT_BUFFER": tt.buffer})
+		if tt.stop == "" {
+			c.cfg.DefaultStopTimeout = ""
+		}
+
+		buckets := durationBuckets(c.restartDeadline())
+		if len(buckets) < 2 || buckets[len(buckets)-1] != tt.last {
+			t.Errorf("CURL_TIMEOUT=%s stop=%q buffer=%s: buckets %v, want them to end at %v", tt.timeout, tt.stop, tt.buffer, buckets, tt.last)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var metricRule = regexp.MustCompile(`^\s*([a-zA-Z_:][a-zA-Z0-9_:]*)\s*(\{[^}]*\})?\s*(>=|<=|==|!=|>|<)\s*(\S+)\s*$`)
+
+type thresholdRule struct {
+	Name   string
+	Labels map[string]string
+	Op     string
+	Value  float64
+}
+
+func parseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}"))
+
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 || len(s) < eq+2 || s[eq+1] != '"' {
+			return nil, fmt.Errorf("malformed labels near %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+
+		var value strings.Builder
+		i := eq + 2
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, fmt.Errorf("unterminated label value for %s", key)
+		}
+		labels[key] = value.String()
+
+		s = strings.TrimSpace(s[i+1:])
+		s = strings.TrimSpace(strings.TrimPrefix(s, ","))
+	}
+
+	return labels, nil
+}
+
+func parseThresholdRule(rule string) (*thresholdRule, error) {
+	m := metricRule.FindStringSubmatch(rule)
+	if m == nil {
+		return nil, fmt.Errorf("invalid metrics rule %q", rule)
+	}
+
+	labels, err := parseLabels(m[2])
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := strconv.ParseFloat(m[4], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold %q", m[4])
+	}
+
+	return &thresholdRule{Name: m[1], Labels: labels, Op: m[3], Value: value}, nil
+}
+
+func (r *thresholdRule) holds(v float64) bool {
+	switch r.Op {
+	case ">":
+		return v > r.Value
+	case ">=":
+		return v >= r.Value
+	case "<":
+		return v < r.Value
+	case "<=":
+		return v <= r.Value
+	case "==":
+		return v == r.Value
+	case "!=":
+		return v != r.Value
+	}
+
+	return false
+}
+
+func (r *thresholdRule) matches(line string) (float64, bool) {
+	if !strings.HasPrefix(line, r.Name) {
+		return 0, false
+	}
+	rest := line[len(r.Name):]
+
+	labels := map[string]string{}
+	if strings.HasPrefix(rest, "{") {
+		end := strings.LastIndexByte(rest, '}')
+		if end < 0 {
+			return 0, false
+		}
+		var err error
+		if labels, err = parseLabels(rest[:end+1]); err != nil {
+			return 0, false
+		}
+		rest = rest[end+1:]
+	} else if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, false
+	}
+
+	for k, v := range r.Labels {
+		if labels[k] != v {
+			return 0, false
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+
+	return v, err == nil
+}
+
+func (c *Client) evaluateMetrics(container Container) (bool, float64, error) {
+	rule, err := parseThresholdRule(container.Labels["autoheal.metrics.rule"])
+	if err != nil {
+		return false, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.cfg.MetricsScanTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, container.Labels["autoheal.metrics.url"], nil)
+	if err != nil {
+		return false, 0, err
+	}
+	response, err := c.httpw.Do(request)
+	if err != nil {
+		return false, 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return false, 0, fmt.Errorf("metrics endpoint returned status %d", response.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(response.Body, 16<<20))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if v, ok := rule.matches(line); ok && rule.holds(v) {
+			return true, v, nil
+		}
+	}
+
+	return false, 0, scanner.Err()
+}
+
+func (c *Client) scanMetrics(f *labelFilter) []candidate {
+	if !c.cfg.MetricsScan {
+		return nil
+	}
+
+	qs := map[string][]string{"label": []string{"autoheal.metrics.url", "autoheal.metrics.rule"}}
+	containers, err := c.listFiltered(f, qs)
+	if err != nil {
+		errorf("Failed to list containers for metrics scanning. %s\n", err)
+		return nil
+	}
+
+	return scanEach(containers, func(container Container) *candidate {
+		if container.Name() == "" || container.State != "running" || disabled(container) {
+			return nil
+		}
+
+		t := time.Now().Format(TIME_FORMAT)
+		id := shortID(container.Id)
+
+		crossed, value, err := c.evaluateMetrics(container)
+		if err != nil {
+			containerLog(container.Name(), id).errorf("%s Failed to scrape metrics of container %s (%s). %s\n", t, container.Name(), id, c.scrub(container, "autoheal.metrics.url", err.Error()))
+			return nil
+		}
+		if !crossed {
+			return nil
+		}
+
+		containerLog(container.Name(), id).debugf("%s Container %s (%s) crossed its metrics rule %s with %v.\n", t, container.Name(), id, c.scrub(container, "autoheal.metrics.rule", container.Labels["autoheal.metrics.rule"]), value)
+		return &candidate{Container: container, Reason: "crossed its metrics rule"}
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsScanHitsAreGated(t *testing.T) {
+	exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "queue_depth 5000")
+	}))
+	defer exporter.Close()
+
+	container := Container{Id: "0123456789abcdef", Names: []string{"/worker"}, State: "running", Labels: map[string]string{
+		"autoheal.metrics.url":          exporter.URL,
+		"autoheal.metrics.rule":         "queue_depth > 1000",
+		"autoheal.max_restarts_per_day": "1",
+	}}
+	d := &fakeAPI{listed: func(filters string) []Container {
+		if strings.Contains(filters, "autoheal.metrics.url") {
+			return []Container{container}
+		}
+		return nil
+	}}
+	c := newFakeClient(t, d, map[string]string{"AUTOHEAL_METRICS_SCAN": "true"})
+	c.history[container.Id] = &restartHistory{Restarts: []time.Time{time.Now().Add(-time.Hour)}}
+
+	c.runOnce(c.ctx)
+	if n := d.count("restart"); n != 0 {
+		t.Fatalf("metrics scan hit past its daily limit reached the daemon %d time(s)", n)
+	}
+
+	delete(c.history, container.Id)
+	c.runOnce(c.ctx)
+	if n := d.count("restart"); n != 1 {
+		t.Errorf("metrics scan hit reached the daemon %d time(s), want 1", n)
+	}
+}
+
+func TestMetricsScanIsConcurrent(t *testing.T) {
+	hung := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hung:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(hung)
+	exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "queue_depth 5000")
+	}))
+	defer exporter.Close()
+
+	var containers []Container
+	for i, url := range []string{slow.URL, slow.URL, slow.URL, exporter.URL} {
+		containers = append(containers, Container{Id: fmt.Sprintf("%016x", i), Names: []string{fmt.Sprintf("/worker%d", i)}, State: "running", Labels: map[string]string{
+			"autoheal.metrics.url":  url,
+			"autoheal.metrics.rule": "queue_depth > 1000",
+		}})
+	}
+	d := &fakeAPI{listed: func(string) []Container { return containers }}
+	c := newFakeClient(t, d, map[string]string{"AUTOHEAL_METRICS_SCAN": "true", "AUTOHEAL_METRICS_SCAN_TIMEOUT": "1"})
+
+	start := time.Now()
+	hits := c.scanMetrics(c.filter.Load())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("scanning three hung endpoints took %s, want about one AUTOHEAL_METRICS_SCAN_TIMEOUT", elapsed)
+	}
+	if len(hits) != 1 || hits[0].Container.Id != containers[3].Id {
+		t.Errorf("hits = %v, want only %s", hits, containers[3].Name())
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+const OTLP_PROTOCOL = "http/protobuf"
+
+func (c *Client) metricExporters() map[string]bool {
+	exporters := map[string]bool{}
+	for _, e := range strings.Split(c.cfg.MetricsExporter, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			exporters[e] = true
+		}
+	}
+	if len(exporters) == 0 {
+		exporters["prometheus"] = true
+		exporters["otlp"] = c.cfg.OtlpEndpoint != ""
+	}
+
+	return exporters
+}
+
+func (c *Client) metricReaders() ([]metric.Reader, error) {
+	var readers []metric.Reader
+	for name, enabled := range c.metricExporters() {
+		if !enabled {
+			continue
+		}
+		switch name {
+		case "prometheus":
+			exporter, err := prometheus.New()
+			if err != nil {
+				return nil, err
+			}
+			readers = append(readers, exporter)
+		case "otlp":
+			reader, err := c.otlpReader()
+			if err != nil {
+				return nil, err
+			}
+			readers = append(readers, reader)
+		case "none":
+		default:
+			return nil, fmt.Errorf("unknown OTEL_METRICS_EXPORTER %q", name)
+		}
+	}
+
+	return readers, nil
+}
+
+func (c *Client) otlpReader() (metric.Reader, error) {
+	if c.cfg.OtlpProtocol != OTLP_PROTOCOL {
+		return nil, fmt.Errorf("unsupported OTEL_EXPORTER_OTLP_PROTOCOL %q, only %s is available", c.cfg.OtlpProtocol, OTLP_PROTOCOL)
+	}
+
+	exporter, err := otlpmetrichttp.New(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return metric.NewPeriodicReader(exporter,
+		metric.WithInterval(time.Duration(c.cfg.OtlpInterval)*time.Millisecond),
+		metric.WithTimeout(time.Duration(c.cfg.OtlpTimeout)*time.Millisecond),
+	), nil
+}
+
+func (c *Client) shutdownMetrics() {
+	if c.provider == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.RequestTimeout)
+	defer cancel()
+	if err := c.provider.Shutdown(ctx); err != nil {
+		errorf("Failed to flush metrics. %s\n", err)
+	}
+}
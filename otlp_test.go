@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/exporters/prometheus"
+)
+
+func TestMetricReaders(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		otlp bool
+	}{
+		{"no endpoint", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": ""}, false},
+		{"endpoint", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://127.0.0.1:4318"}, true},
+		{"metrics endpoint", map[string]string{"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": "http://127.0.0.1:4318/v1/metrics"}, true},
+		{"endpoint with prometheus only", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://127.0.0.1:4318", "OTEL_METRICS_EXPORTER": "prometheus"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, tt.env)
+			readers, err := c.metricReaders()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			otlp := false
+			for _, r := range readers {
+				if _, ok := r.(*prometheus.Exporter); !ok {
+					otlp = true
+				}
+			}
+			if otlp != tt.otlp {
+				t.Errorf("has OTLP reader = %v, want %v", otlp, tt.otlp)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+func lookupId(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(id)
+}
+
+func primaryGroup(name string) (int, error) {
+	lookup := user.Lookup
+	if _, err := strconv.Atoi(name); err == nil {
+		lookup = user.LookupId
+	}
+
+	u, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(u.Gid)
+}
+
+func (c *Client) dropPrivileges() error {
+	if c.cfg.RunAsUser == "" && c.cfg.RunAsGroup == "" {
+		return nil
+	}
+
+	gid := -1
+	if c.cfg.RunAsGroup != "" {
+		var err error
+		gid, err = lookupId(c.cfg.RunAsGroup, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("unknown group %s: %w", c.cfg.RunAsGroup, err)
+		}
+	} else if c.cfg.RunAsUser != "" {
+		var err error
+		gid, err = primaryGroup(c.cfg.RunAsUser)
+		if err != nil {
+			return fmt.Errorf("unknown primary group of user %s, set AUTOHEAL_GROUP: %w", c.cfg.RunAsUser, err)
+		}
+	}
+
+	if gid >= 0 {
+		if err := syscall.Setgroups([]int{gid}); err != nil {
+			return err
+		}
+		if err := syscall.Setgid(gid); err != nil {
+			return err
+		}
+	}
+
+	if c.cfg.RunAsUser != "" {
+		uid, err := lookupId(c.cfg.RunAsUser, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("unknown user %s: %w", c.cfg.RunAsUser, err)
+		}
+		if err := syscall.Setuid(uid); err != nil {
+			return err
+		}
+	}
+
+	infof("Dropped privileges to uid %d gid %d\n", syscall.Getuid(), syscall.Getgid())
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestPrimaryGroup(t *testing.T) {
+	for _, name := range []string{"root", "0"} {
+		gid, err := primaryGroup(name)
+		if err != nil {
+			t.Fatalf("primaryGroup(%q) failed: %s", name, err)
+		}
+		if gid != 0 {
+			t.Errorf("primaryGroup(%q) = %d, want 0", name, gid)
+		}
+	}
+
+	if _, err := primaryGroup("no-such-user-autoheal"); err == nil {
+		t.Error("primaryGroup of an unknown user did not fail")
+	}
+}
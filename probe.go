@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+func (c *Client) probe(url string) error {
+	response, err := c.httpw.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("probe returned status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *Client) confirmUnhealthy(container Container) (bool, int, int) {
+	url := container.Labels["autoheal.probe.url"]
+	if url == "" {
+		return true, 0, 0
+	}
+
+	threshold, err := strconv.Atoi(container.Labels["autoheal.probe.failures"])
+	if err != nil || threshold < 1 {
+		threshold = 1
+	}
+
+	probeErr := c.probe(url)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stateFor(container.Id)
+	if probeErr == nil {
+		s.ProbeFailures = 0
+		return false, 0, threshold
+	}
+	s.ProbeFailures++
+
+	return s.ProbeFailures >= threshold, s.ProbeFailures, threshold
+}
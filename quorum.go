@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func (c *Client) quorumAllows(container Container, id string, t string) bool {
+	group := container.Labels["autoheal.quorum.group"]
+	required, err := strconv.Atoi(container.Labels["autoheal.quorum.min"])
+	if group == "" || err != nil || required < 1 {
+		return true
+	}
+
+	members, err := c.listContainers(map[string][]string{
+		"label":  []string{"autoheal.quorum.group=" + group},
+		"health": []string{"healthy"},
+	})
+	if err != nil {
+		containerLog(container.Name(), id).errorf("%s Failed to check quorum group %s of container %s (%s) - don't restart. %s\n", t, group, container.Name(), id, err)
+		return false
+	}
+
+	healthy := 0
+	for _, m := range members {
+		if m.Id != container.Id {
+			healthy++
+		}
+	}
+	if healthy >= required {
+		return true
+	}
+
+	containerLog(container.Name(), id).infof("%s Container %s (%s) belongs to quorum group %s with %d/%d healthy members - don't restart.\n", t, container.Name(), id, group, healthy, required)
+
+	c.mu.Lock()
+	s := c.stateFor(container.Id)
+	alert := !s.QuorumAlerted
+	s.QuorumAlerted = true
+	c.mu.Unlock()
+
+	if alert {
+		e := Event{Time: t, Container: container.Name(), Id: id, Result: RESULT_BLOCKED, Message: fmt.Sprintf("Quorum group %s has only %d of %d required healthy members, not restarting the container", group, healthy, required)}
+		if err := c.notify(e); err != nil {
+			errorf("Failed to call webhook. %s\n", err)
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+)
+
+func (c *Client) reportRecovered(gone map[string]string) {
+	for fullId, name := range gone {
+		if c.hostShuttingDown() {
+			return
+		}
+
+		t := time.Now().Format(TIME_FORMAT)
+		id := shortID(fullId)
+
+		inspect, err := c.inspectContainer(fullId)
+		if err != nil {
+			containerLog(name, id).warnf("%s Container %s (%s) is no longer unhealthy but could not be inspected, probably removed. %s\n", t, name, id, err)
+			continue
+		}
+		if !inspect.State.Running || inspect.HealthStatus() == "unhealthy" {
+			containerLog(name, id).warnf("%s Container %s (%s) is no longer listed as unhealthy but is %s.\n", t, name, id, inspect.State.Status)
+			continue
+		}
+
+		containerLog(name, id).infof("%s Container %s (%s) recovered.\n", t, name, id)
+		c.resetAttempts(fullId)
+		if c.cfg.MetricsEnabled {
+			c.recoveries.Add(c.ctx, 1)
+		}
+
+		e := Event{Time: t, Container: name, Id: id, Result: RESULT_RECOVERED, Message: "Container recovered", EventId: newEventId()}
+		if err := c.notify(e); err != nil {
+			errorf("Failed to call webhook. %s\n", err)
+		}
+	}
+}
+
+func (c *Client) resetAttempts(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if h, ok := c.history[id]; ok {
+		h.RecoveredAt = time.Now()
+		h.Abandoned = false
+	}
+}
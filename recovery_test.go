@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecoveryReported(t *testing.T) {
+	out := captureLog(t)
+	c, reader := newMeteredClient(t, map[string]string{})
+
+	web := Container{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}
+	db := Container{Id: "fedcba9876543210", Names: []string{"/db"}, State: "running"}
+	cache := Container{Id: "00112233445566778899", Names: []string{"/cache"}, State: "running"}
+	healthy := &ContainerInspect{}
+	healthy.State.Running = true
+	healthy.State.Health = &ContainerHealth{Status: "healthy"}
+	d := &fakeAPI{unhealthy: []Container{web, db, cache}, inspects: map[string]*ContainerInspect{web.Id: healthy}}
+	c.docker = d
+
+	if _, err := c.runOnce(c.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "recovered.") {
+		t.Fatalf("reported a recovery while every container was unhealthy:\n%s", out)
+	}
+
+	d.mu.Lock()
+	d.unhealthy = []Container{cache}
+	d.mu.Unlock()
+	if _, err := c.runOnce(c.ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	log := out.String()
+	if !strings.Contains(log, "Container /web (0123456789ab) recovered.") {
+		t.Errorf("log does not report /web recovered:\n%s", log)
+	}
+	if strings.Contains(log, "Container /db (fedcba987654) recovered.") || !strings.Contains(log, "Container /db (fedcba987654) is no longer unhealthy but could not be inspected, probably removed.") {
+		t.Errorf("log does not tell the removed /db apart:\n%s", log)
+	}
+	if got := counters(t, reader, "recovery"); got[""] != 1 {
+		t.Errorf("recovery = %v, want 1", got)
+	}
+}
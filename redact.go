@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+func parseRedactLabels(value string) []string {
+	var patterns []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+
+	return patterns
+}
+
+func (c *Client) sensitive(key string) bool {
+	for _, pattern := range c.cfg.RedactLabels {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Client) scrub(container Container, key string, s string) string {
+	if v := container.Labels[key]; v != "" && c.sensitive(key) {
+		return strings.ReplaceAll(s, v, REDACTED)
+	}
+
+	return s
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var healthStates = map[string]bool{"unhealthy": true, "healthy": true, "starting": true, "none": true}
+
+var containerStates = map[string]bool{"created": true, "restarting": true, "running": true, "removing": true, "paused": true, "exited": true, "dead": true}
+
+type labelFilter struct {
+	Label   string
+	Labels  []string
+	States  []string
+	Queries []string
+}
+
+func newLabelFilter(label string, states string) (*labelFilter, error) {
+	f := &labelFilter{Label: label}
+	for _, l := range strings.Split(label, ",") {
+		if l = strings.TrimSpace(l); l == "all" {
+			f.Labels = nil
+			break
+		} else if l != "" {
+			f.Labels = append(f.Labels, l)
+		}
+	}
+
+	for _, state := range strings.Split(states, ",") {
+		state = strings.TrimSpace(state)
+		var base map[string][]string
+		suffix := ""
+		switch {
+		case state == "":
+			continue
+		case healthStates[state]:
+			base = map[string][]string{"health": []string{state}}
+		case containerStates[state]:
+			base, suffix = map[string][]string{"status": []string{state}}, "&all=1"
+		default:
+			return nil, fmt.Errorf("unknown state %q in AUTOHEAL_MONITOR_STATES", state)
+		}
+		f.States = append(f.States, state)
+
+		for _, qs := range f.scope(base) {
+			query, err := json.Marshal(qs)
+			if err != nil {
+				return nil, err
+			}
+			f.Queries = append(f.Queries, string(query)+suffix)
+		}
+	}
+	if len(f.States) == 0 {
+		return nil, fmt.Errorf("AUTOHEAL_MONITOR_STATES is empty")
+	}
+
+	return f, nil
+}
+
+func (f *labelFilter) Monitors(inspect *ContainerInspect) bool {
+	for _, state := range f.States {
+		if state == inspect.HealthStatus() || (state == "none" && inspect.State.Health == nil) || state == inspect.State.Status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *labelFilter) Matches(container Container) bool {
+	if f.All() {
+		return true
+	}
+
+	for _, label := range f.Labels {
+		if container.Labels[label] == "true" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *labelFilter) All() bool {
+	return len(f.Labels) == 0
+}
+
+func (f *labelFilter) scope(qs map[string][]string) []map[string][]string {
+	if f.All() {
+		return []map[string][]string{qs}
+	}
+
+	scoped := make([]map[string][]string, 0, len(f.Labels))
+	for _, label := range f.Labels {
+		q := make(map[string][]string, len(qs)+1)
+		for k, v := range qs {
+			q[k] = v
+		}
+		q["label"] = append(append([]string{}, qs["label"]...), label+"=true")
+		scoped = append(scoped, q)
+	}
+
+	return scoped
+}
+
+func (c *Client) reloadFilter() error {
+	if path := os.Getenv("AUTOHEAL_CONFIG_FILE"); path != "" {
+		values, err := loadConfigFile(path)
+		if err != nil {
+			return err
+		}
+		fileValues.Store(&values)
+	}
+
+	f, err := newLabelFilter(getEnv("AUTOHEAL_CONTAINER_LABEL", "all"), getEnv("AUTOHEAL_MONITOR_STATES", "unhealthy"))
+	if err != nil {
+		return err
+	}
+
+	if old := c.filter.Swap(f); old.Label != f.Label {
+		infof("%s Container label filter changed from %s to %s, effective next cycle.\n", time.Now().Format(TIME_FORMAT), old.Label, f.Label)
+	}
+
+	return nil
+}
+
+func (c *Client) watchReload() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	for range ch {
+		c.reload()
+	}
+}
+
+// reload re-reads the settings that can change at runtime: the container
+// label and state filter and the webhook template. Everything else is read
+// once at startup.
+func (c *Client) reload() {
+	t := time.Now().Format(TIME_FORMAT)
+
+	var reloaded []string
+	if err := c.reloadFilter(); err != nil {
+		errorf("%s Failed to reload the container filter, keeping the previous one. %s\n", t, err)
+	} else {
+		reloaded = append(reloaded, "container filter")
+	}
+	if err := c.loadTemplate(); err != nil {
+		errorf("%s Failed to reload webhook template, keeping the previous one. %s\n", t, err)
+	} else {
+		reloaded = append(reloaded, "webhook template")
+	}
+
+	if len(reloaded) > 0 {
+		infof("%s Reloaded the %s - other settings take effect on restart.\n", t, strings.Join(reloaded, " and "))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReloadDuringCycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autoheal.json")
+	if err := os.WriteFile(path, []byte(`{"container_label": "autoheal"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fileValues.Store(nil) })
+
+	d := &fakeAPI{unhealthy: []Container{{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running", Labels: map[string]string{"autoheal": "true"}}}}
+	c := newFakeClient(t, d, map[string]string{"AUTOHEAL_CONFIG_FILE": path})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if err := c.reloadFilter(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if _, err := c.runOnce(c.ctx); err != nil {
+			t.Fatal(err)
+		}
+		if label := getEnv("AUTOHEAL_CONTAINER_LABEL", "all"); label != "autoheal" && label != "all" {
+			t.Fatalf("getEnv during reload = %q", label)
+		}
+	}
+	wg.Wait()
+
+	if got := c.filter.Load().Label; got != "autoheal" {
+		t.Errorf("filter label after reload = %q, want %q", got, "autoheal")
+	}
+}
+
+func TestReloadLogsWhatChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autoheal.json")
+	if err := os.WriteFile(path, []byte(`{"container_label": "autoheal"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fileValues.Store(nil) })
+
+	tmpl := filepath.Join(t.TempDir(), "webhook.tmpl")
+	if err := os.WriteFile(tmpl, []byte(`{"text": "{{.Message}}"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := captureLog(t)
+	c := newFakeClient(t, &fakeAPI{}, map[string]string{"AUTOHEAL_CONFIG_FILE": path, "WEBHOOK_TEMPLATE_FILE": tmpl})
+
+	c.reload()
+	if !strings.Contains(out.String(), "Reloaded the container filter and webhook template - other settings take effect on restart.") {
+		t.Errorf("reload log = %q, want the filter and template named", out)
+	}
+
+	seen := len(out.String())
+	os.Remove(tmpl)
+	c.reload()
+	if got := out.String()[seen:]; !strings.Contains(got, "Failed to reload webhook template") || !strings.Contains(got, "Reloaded the container filter - other") {
+		t.Errorf("reload log = %q, want only the filter reported as reloaded", got)
+	}
+}
+
+func TestLabelFilterQueries(t *testing.T) {
+	tests := []struct {
+		label string
+		want  []string
+	}{
+		{"all", []string{`{"health":["unhealthy"]}`}},
+		{"autoheal", []string{`{"health":["unhealthy"],"label":["autoheal=true"]}`}},
+		{"autoheal, monitoring.restart", []string{
+			`{"health":["unhealthy"],"label":["autoheal=true"]}`,
+			`{"health":["unhealthy"],"label":["monitoring.restart=true"]}`,
+		}},
+		{"autoheal,all", []string{`{"health":["unhealthy"]}`}},
+	}
+
+	for _, tt := range tests {
+		f, err := newLabelFilter(tt.label, "unhealthy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(f.Queries, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("AUTOHEAL_CONTAINER_LABEL=%q queries = %q, want %q", tt.label, f.Queries, tt.want)
+		}
+	}
+}
+
+func TestMonitorStates(t *testing.T) {
+	f, err := newLabelFilter("all", "unhealthy, exited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`{"health":["unhealthy"]}`, `{"status":["exited"]}&all=1`}
+	if strings.Join(f.Queries, "\n") != strings.Join(want, "\n") {
+		t.Errorf("queries = %q, want %q", f.Queries, want)
+	}
+
+	exited := &ContainerInspect{}
+	exited.State.Status = "exited"
+	running := &ContainerInspect{}
+	running.State.Status = "running"
+	running.State.Health = &ContainerHealth{Status: "healthy"}
+	if !f.Monitors(exited) || f.Monitors(running) {
+		t.Errorf("Monitors(exited) = %v, Monitors(healthy) = %v, want true and false", f.Monitors(exited), f.Monitors(running))
+	}
+
+	if _, err := newLabelFilter("all", "unhealthy,sleeping"); err == nil {
+		t.Error("accepted an unknown state")
+	}
+	if _, err := newLabelFilter("all", " , "); err == nil {
+		t.Error("accepted an empty AUTOHEAL_MONITOR_STATES")
+	}
+
+	d := &fakeAPI{unhealthy: []Container{
+		{Id: "0123456789abcdef", Names: []string{"/web"}, State: "exited"},
+		{Id: "fedcba9876543210", Names: []string{"/db"}, State: RESTARTING},
+		{Id: "00112233445566778899", State: "dead"},
+	}}
+	c := newFakeClient(t, d, map[string]string{"AUTOHEAL_MONITOR_STATES": "unhealthy,exited,dead"})
+	if _, err := c.runOnce(c.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.calls) != 1 || d.calls[0] != "restart 0123456789abcdef" {
+		t.Errorf("docker calls = %v, want only the exited /web restarted", d.calls)
+	}
+}
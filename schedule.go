@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cronAliases = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+type cronField struct {
+	any    bool
+	values map[int]bool
+}
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow cronField
+}
+
+func parseCronField(field string, min int, max int) (cronField, error) {
+	f := cronField{any: field == "*", values: map[int]bool{}}
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return f, fmt.Errorf("invalid step in %q", part)
+			}
+			step, part = s, part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return f, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return f, fmt.Errorf("invalid range %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return f, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			f.values[v] = true
+		}
+	}
+
+	return f, nil
+}
+
+func parseCron(expr string) (*cronSchedule, error) {
+	if alias, ok := cronAliases[strings.TrimSpace(expr)]; ok {
+		expr = alias
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q needs 5 fields", expr)
+	}
+
+	var s cronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if s.dow.values[7] {
+		s.dow.values[0] = true
+	}
+
+	return &s, nil
+}
+
+func (s *cronSchedule) Matches(t time.Time) bool {
+	if !s.minute.values[t.Minute()] || !s.hour.values[t.Hour()] || !s.month.values[int(t.Month())] {
+		return false
+	}
+
+	dom, dow := s.dom.values[t.Day()], s.dow.values[int(t.Weekday())]
+	if !s.dom.any && !s.dow.any {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+func (c *Client) runScheduled(now time.Time) {
+	c.scanning.Lock()
+	defer c.scanning.Unlock()
+
+	f := c.filter.Load()
+	qs := map[string][]string{"label": []string{"autoheal.schedule"}}
+	containers, err := c.listFiltered(f, qs)
+	if err != nil {
+		errorf("Failed to list containers for scheduled restarts. %s\n", err)
+		return
+	}
+
+	var candidates []candidate
+	for _, container := range containers {
+		if container.Name() == "" || disabled(container) {
+			continue
+		}
+
+		t := time.Now().Format(TIME_FORMAT)
+		id := shortID(container.Id)
+
+		schedule, err := parseCron(container.Labels["autoheal.schedule"])
+		if err != nil {
+			containerLog(container.Name(), id).warnf("%s Container %s (%s) has an invalid autoheal.schedule. %s\n", t, container.Name(), id, err)
+			continue
+		}
+		if schedule.Matches(now) {
+			candidates = append(candidates, candidate{Container: container, Reason: REASON_SCHEDULED})
+		}
+	}
+
+	c.remediate(c.ctx, candidates)
+}
+
+func (c *Client) watchSchedules() {
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-time.After(time.Until(next)):
+			c.runScheduled(next)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunScheduledHonoursCooldown(t *testing.T) {
+	container := Container{Id: "0123456789abcdef", Names: []string{"/backup"}, State: "running", Labels: map[string]string{"autoheal.schedule": "* * * * *"}}
+	d := &fakeAPI{listed: func(string) []Container { return []Container{container} }}
+	c := newFakeClient(t, d, map[string]string{"AUTOHEAL_COOLDOWN": "600"})
+
+	c.history[container.Id] = &restartHistory{Restarts: []time.Time{time.Now()}}
+	c.runScheduled(time.Now())
+	if n := d.count("restart"); n != 0 {
+		t.Fatalf("scheduled restart inside the cooldown reached the daemon %d time(s)", n)
+	}
+
+	delete(c.history, container.Id)
+	c.runScheduled(time.Now())
+	if n := d.count("restart"); n != 1 {
+		t.Errorf("scheduled restart reached the daemon %d time(s), want 1", n)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+func (c *Client) notifyReady() {
+	if err := sdNotify("READY=1"); err != nil {
+		errorf("Failed to notify systemd readiness. %s\n", err)
+	}
+
+	c.watchdog = watchdogInterval()
+	if c.watchdog == 0 {
+		return
+	}
+	if c.cfg.Mode == MODE_EVENTS {
+		go c.watchEventsWatchdog()
+	} else if c.cfg.Interval >= c.watchdog/2 {
+		warnf("AUTOHEAL_INTERVAL %s is too long for the systemd watchdog of %s, the service may be restarted.\n", c.cfg.Interval, c.watchdog)
+	}
+}
+
+// watchEventsWatchdog keeps the watchdog fed in events mode, where cycles
+// only run on events and resyncs, for as long as the event stream is up.
+func (c *Client) watchEventsWatchdog() {
+	for {
+		select {
+		case <-time.After(c.watchdog / 2):
+			if c.eventsUp.Load() {
+				c.pingWatchdog()
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Client) pingWatchdog() {
+	if c.watchdog == 0 {
+		return
+	}
+
+	if err := sdNotify("WATCHDOG=1"); err != nil {
+		errorf("Failed to ping systemd watchdog. %s\n", err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventsWatchdogFollowsStream(t *testing.T) {
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "notify.sock"), Net: "unixgram"}
+	server, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	t.Setenv("NOTIFY_SOCKET", addr.Name)
+	t.Setenv("WATCHDOG_USEC", "100000")
+	c := newTestClient(t, map[string]string{"METRICS_ENABLED": "false", "AUTOHEAL_MODE": MODE_EVENTS})
+
+	buf := make([]byte, 64)
+	read := func() string {
+		server.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		n, err := server.Read(buf)
+		if err != nil {
+			return ""
+		}
+		return string(buf[:n])
+	}
+
+	c.notifyReady()
+	if got := read(); got != "READY=1" {
+		t.Fatalf("first notification = %q, want READY=1", got)
+	}
+	if got := read(); got != "" {
+		t.Errorf("notified %q while the event stream was down", got)
+	}
+
+	c.eventsUp.Store(true)
+	if got := read(); got != "WATCHDOG=1" {
+		t.Errorf("notification with the event stream up = %q, want WATCHDOG=1", got)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sort"
+)
+
+func composeService(container Container) string {
+	service := container.Labels["com.docker.compose.service"]
+	if service == "" {
+		return ""
+	}
+
+	return container.Labels["com.docker.compose.project"] + "/" + service
+}
+
+func (c *Client) sortByAge(containers []Container) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	detected := func(id string) int64 {
+		if s, ok := c.state[id]; ok {
+			return s.DetectedAt.UnixNano()
+		}
+		return 0
+	}
+
+	sort.SliceStable(containers, func(i, j int) bool {
+		return detected(containers[i].Id) < detected(containers[j].Id)
+	})
+}
+
+func (c *Client) serviceThrottled(container Container, restarted map[string]int, id string, t string) bool {
+	service := composeService(container)
+	if c.cfg.MaxPerServicePerCycle <= 0 || service == "" {
+		return false
+	}
+
+	if restarted[service] < c.cfg.MaxPerServicePerCycle {
+		restarted[service]++
+		return false
+	}
+
+	containerLog(container.Name(), id).infof("%s Container %s (%s) of service %s deferred - %d replica(s) already restarted this cycle.\n", t, container.Name(), id, service, restarted[service])
+	return true
+}
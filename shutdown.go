@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func (c *Client) watchShutdown() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
+
+	sig := <-ch
+	c.shutdown.Store(true)
+	infof("%s Received %s, shutting down - suppressing restarts.\n", time.Now().Format(TIME_FORMAT), sig)
+	c.cancel()
+}
+
+func (c *Client) hostShuttingDown() bool {
+	down := c.shutdown.Load()
+	if !down && c.cfg.ShutdownSentinel != "" {
+		_, err := os.Stat(c.cfg.ShutdownSentinel)
+		down = err == nil
+	}
+
+	if c.suppressed.CompareAndSwap(!down, down) {
+		if down {
+			infof("%s Host shutting down - suppressing all restarts.\n", time.Now().Format(TIME_FORMAT))
+		} else {
+			infof("%s Host shutdown cancelled - resuming restarts.\n", time.Now().Format(TIME_FORMAT))
+		}
+	}
+
+	return down
+}
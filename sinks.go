@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const SINK_QUEUE_SIZE = 100
+
+const (
+	SEVERITY_INFO     = "info"
+	SEVERITY_WARNING  = "warning"
+	SEVERITY_CRITICAL = "critical"
+)
+
+func (e Event) Severity() string {
+	switch e.Result {
+	case RESULT_FAILURE, RESULT_ESCALATED, RESULT_FAILED:
+		return SEVERITY_CRITICAL
+	case RESULT_BLOCKED, RESULT_DEFERRED:
+		return SEVERITY_WARNING
+	}
+
+	return SEVERITY_INFO
+}
+
+type sinkFilter map[string]bool
+
+func parseSinkFilter(value string) sinkFilter {
+	f := sinkFilter{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			f[v] = true
+		}
+	}
+
+	return f
+}
+
+func (f sinkFilter) accepts(e Event) bool {
+	return len(f) == 0 || f["all"] || f[e.Result] || f[e.Severity()]
+}
+
+func parseFormat(name string, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return tmpl, nil
+}
+
+func render(tmpl *template.Template, e Event) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, e); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// sinkQueue hands events to a sink from its own goroutine, so a broker or API
+// that is down never holds up a restart worker.
+type sinkQueue struct {
+	name   string
+	send   func(Event) error
+	mu     sync.RWMutex
+	events chan Event
+	done   chan struct{}
+}
+
+func newSinkQueue(name string, send func(Event) error) *sinkQueue {
+	q := &sinkQueue{name: name, send: send, events: make(chan Event, SINK_QUEUE_SIZE), done: make(chan struct{})}
+
+	go func(events chan Event) {
+		defer close(q.done)
+		for e := range events {
+			if err := q.send(e); err != nil {
+				errorf("Failed to %s. %s\n", q.name, err)
+			}
+		}
+	}(q.events)
+
+	return q
+}
+
+func (q *sinkQueue) enqueue(e Event) {
+	if q == nil {
+		return
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.events == nil {
+		return
+	}
+
+	select {
+	case q.events <- e:
+	default:
+		warnf("Failed to %s, the queue is full (%d) - dropping the event.\n", q.name, SINK_QUEUE_SIZE)
+	}
+}
+
+func (q *sinkQueue) drain() {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	events := q.events
+	q.events = nil
+	q.mu.Unlock()
+
+	if events != nil {
+		close(events)
+		<-q.done
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type sshDialer struct {
+	mu     sync.Mutex
+	addr   string
+	socket string
+	config *ssh.ClientConfig
+	client *ssh.Client
+}
+
+func newSSHDialer(cfg *config) (*sshDialer, error) {
+	u, err := url.Parse(cfg.DockerHost)
+	if err != nil {
+		return nil, err
+	}
+
+	home, _ := os.UserHomeDir()
+	keyPath, knownHosts := cfg.SSHKey, cfg.SSHKnownHosts
+	if keyPath == "" {
+		keyPath = filepath.Join(home, ".ssh", "id_ed25519")
+		if _, err := os.Stat(keyPath); err != nil {
+			keyPath = filepath.Join(home, ".ssh", "id_rsa")
+		}
+	}
+	if knownHosts == "" {
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", keyPath, err)
+	}
+	hostKey, err := knownhosts.New(knownHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	user := u.User.Username()
+	if user == "" {
+		user = "root"
+	}
+	port := u.Port()
+	if port == "" {
+		port = "22"
+	}
+	socket := u.Path
+	if socket == "" || socket == "/" {
+		socket = "/var/run/docker.sock"
+	}
+
+	return &sshDialer{
+		addr:   net.JoinHostPort(u.Hostname(), port),
+		socket: socket,
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKey,
+			Timeout:         cfg.RequestTimeout,
+		},
+	}, nil
+}
+
+func (d *sshDialer) DialContext(_ context.Context, _, _ string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		if d.client == nil {
+			client, err := ssh.Dial("tcp", d.addr, d.config)
+			if err != nil {
+				return nil, err
+			}
+			d.client = client
+		}
+
+		conn, err := d.client.Dial(UNIX, d.socket)
+		if err == nil || attempt > 0 {
+			return conn, err
+		}
+
+		d.client.Close()
+		d.client = nil
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+type statsd struct {
+	conn   net.Conn
+	prefix string
+}
+
+func NewStatsd(addr string, prefix string) (*statsd, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &statsd{conn: conn, prefix: prefix}, nil
+}
+
+func (s *statsd) send(name string, value string, kind string, tags map[string]string) {
+	if s == nil {
+		return
+	}
+
+	line := s.prefix + "." + name + ":" + value + "|" + kind
+	if len(tags) > 0 {
+		pairs := make([]string, 0, len(tags))
+		for k, v := range tags {
+			pairs = append(pairs, k+":"+strings.ReplaceAll(v, ",", "_"))
+		}
+		line += "|#" + strings.Join(pairs, ",")
+	}
+
+	if _, err := s.conn.Write([]byte(line)); err != nil {
+		errorf("Failed to send statsd metric. %s\n", err)
+	}
+}
+
+func (s *statsd) Count(name string, tags map[string]string) {
+	s.send(name, "1", "c", tags)
+}
+
+func (s *statsd) Timing(name string, d time.Duration, tags map[string]string) {
+	s.send(name, fmt.Sprint(d.Milliseconds()), "ms", tags)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type lifetimeStats struct {
+	Started     time.Time
+	Results     map[string]int
+	ByContainer map[string]int
+}
+
+func (c *Client) countRestart(container string, result string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Results[result]++
+	c.stats.ByContainer[container]++
+}
+
+func (c *Client) summary() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	results := make([]string, 0, len(c.stats.Results))
+	for result, n := range c.stats.Results {
+		total += n
+		results = append(results, fmt.Sprintf("%d %s", n, result))
+	}
+	sort.Strings(results)
+
+	names := make([]string, 0, len(c.stats.ByContainer))
+	for name := range c.stats.ByContainer {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := c.stats.ByContainer[names[i]], c.stats.ByContainer[names[j]]
+		return a > b || (a == b && names[i] < names[j])
+	})
+	if len(names) > 5 {
+		names = names[:5]
+	}
+	top := make([]string, len(names))
+	for i, name := range names {
+		top[i] = fmt.Sprintf("%s (%d)", name, c.stats.ByContainer[name])
+	}
+
+	s := fmt.Sprintf("Shutting down after %s: %d restarts", time.Since(c.stats.Started).Round(time.Second), total)
+	if len(results) > 0 {
+		s += " (" + strings.Join(results, ", ") + ")"
+	}
+	s += fmt.Sprintf(", %d unique containers", len(c.seen))
+	if len(top) > 0 {
+		s += ". Top offenders: " + strings.Join(top, ", ")
+	}
+
+	return s
+}
+
+func (c *Client) reportSummary() {
+	e := Event{Time: time.Now().Format(TIME_FORMAT), Result: RESULT_SUMMARY, Message: c.summary()}
+	if !c.cfg.WebHookSummary {
+		infof("%s\n", e)
+		return
+	}
+
+	if err := c.notify(e); err != nil {
+		errorf("Failed to call webhook. %s\n", err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("docker_restart")
+
+func (c *Client) startTracing() error {
+	if c.cfg.OtlpTracesEndpoint == "" {
+		return nil
+	}
+	if c.cfg.OtlpTracesProtocol != OTLP_PROTOCOL {
+		return fmt.Errorf("unsupported OTEL_EXPORTER_OTLP_TRACES_PROTOCOL %q, only %s is available", c.cfg.OtlpTracesProtocol, OTLP_PROTOCOL)
+	}
+
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		return err
+	}
+	c.tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(c.tracerProvider)
+
+	return nil
+}
+
+func (c *Client) shutdownTracing() {
+	if c.tracerProvider == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.RequestTimeout)
+	defer cancel()
+	if err := c.tracerProvider.Shutdown(ctx); err != nil {
+		errorf("Failed to flush traces. %s\n", err)
+	}
+}
+
+func traceResult(span trace.Span, err error, attrs ...attribute.KeyValue) {
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	recordSpans sync.Once
+	recorder    = tracetest.NewSpanRecorder()
+)
+
+func spansFor(name string, id string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() != name {
+			continue
+		}
+		for _, kv := range span.Attributes() {
+			if kv.Key == "container.id" && kv.Value.AsString() == id {
+				spans = append(spans, span)
+			}
+		}
+	}
+
+	return spans
+}
+
+func TestRestartSpans(t *testing.T) {
+	recordSpans.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+
+	tests := []struct {
+		id      string
+		err     error
+		status  codes.Code
+		outcome string
+	}{
+		{"aaaaaaaaaaaa", nil, codes.Unset, RESULT_SUCCESS},
+		{"bbbbbbbbbbbb", errors.New("conflict"), codes.Error, RESULT_FAILURE},
+	}
+
+	for _, tt := range tests {
+		d := &fakeAPI{unhealthy: []Container{{Id: tt.id, Names: []string{"/web"}, State: "running"}}, err: tt.err}
+		c := newFakeClient(t, d, map[string]string{})
+		if _, err := c.runOnce(c.ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		spans := spansFor("restart", tt.id)
+		if len(spans) != 1 {
+			t.Fatalf("%s: recorded %d restart spans, want 1", tt.outcome, len(spans))
+		}
+		if got := spans[0].Status().Code; got != tt.status {
+			t.Errorf("%s: span status = %v, want %v", tt.outcome, got, tt.status)
+		}
+		found := false
+		for _, kv := range spans[0].Attributes() {
+			found = found || kv == attribute.String("outcome", tt.outcome)
+		}
+		if !found {
+			t.Errorf("%s: span has no outcome attribute: %v", tt.outcome, spans[0].Attributes())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func registryAuth(value string) string {
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return base64.URLEncoding.EncodeToString([]byte(value))
+	}
+
+	return value
+}
+
+func (c *Client) pullImage(ref string) error {
+	request, err := dockerRequest(http.MethodPost, c.cfg.DockerUrl+"/images/"+"create?fromImage="+url.QueryEscape(ref), nil)
+	if err != nil {
+		return err
+	}
+	if c.cfg.RegistryAuth != "" {
+		request.Header.Set("X-Registry-Auth", registryAuth(c.cfg.RegistryAuth))
+	}
+
+	client := c.httpd
+	client.Timeout = c.cfg.PullTimeout
+	body, err := c.dockerSend(&client, request)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var progress struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&progress); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if progress.Error != "" {
+			return fmt.Errorf("%s", progress.Error)
+		}
+	}
+}
+
+func (c *Client) imageId(ref string) (string, error) {
+	body, err := c.dockerCall(http.MethodGet, c.cfg.DockerUrl+"/images/"+ref+"/json", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var image struct {
+		Id string `json:"Id"`
+	}
+	if err := json.Unmarshal(body, &image); err != nil {
+		return "", err
+	}
+
+	return image.Id, nil
+}
+
+func (c *Client) newerImage(id string) (string, error) {
+	inspect, err := c.inspectContainer(id)
+	if err != nil {
+		return "", err
+	}
+
+	ref := inspect.Config.Image
+	if ref == "" || strings.HasPrefix(ref, "sha256:") || strings.Contains(ref, "@") {
+		return "", nil
+	}
+
+	if err := c.pullImage(ref); err != nil {
+		return "", err
+	}
+
+	latest, err := c.imageId(ref)
+	if err != nil || latest == inspect.Image {
+		return "", err
+	}
+
+	return ref, nil
+}
+
+func (c *Client) updatedImage(container Container, id string, t string) string {
+	image, err := c.newerImage(container.Id)
+	if err != nil {
+		containerLog(container.Name(), id).errorf("%s Failed to check for a newer image of container %s (%s). %s\n", t, container.Name(), id, err)
+		return ""
+	}
+	if image != "" {
+		containerLog(container.Name(), id).infof("%s Container %s (%s) has a newer %s image - Recreating container with it.\n", t, container.Name(), id, image)
+	}
+
+	return image
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPullImageSendsRegistryAuth(t *testing.T) {
+	const auth = `{"username": "ci", "password": "s3cret"}`
+	tests := []struct {
+		name, value, want string
+	}{
+		{"unset", "", ""},
+		{"json", auth, base64.URLEncoding.EncodeToString([]byte(auth))},
+		{"encoded", "eyJ1c2VybmFtZSI6ImNpIn0=", "eyJ1c2VybmFtZSI6ImNpIn0="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/images/create" || r.URL.Query().Get("fromImage") != "nginx:latest" {
+					http.NotFound(w, r)
+					return
+				}
+				got = r.Header.Get("X-Registry-Auth")
+				io.WriteString(w, `{"status": "Pulling from library/nginx"}`+"\n"+`{"status": "Downloaded newer image for nginx:latest"}`+"\n")
+			}))
+			defer srv.Close()
+
+			c := newTestClient(t, map[string]string{
+				"DOCKER_HOST":     "tcp://" + strings.TrimPrefix(srv.URL, "http://"),
+				"METRICS_ENABLED": "false",
+				"REGISTRY_AUTH":   tt.value,
+			})
+			if err := c.pullImage("nginx:latest"); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("X-Registry-Auth = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	SUCCESS_RUNNING     = "running"
+	SUCCESS_HEALTHY     = "healthy"
+	SUCCESS_PORT_OPEN   = "port-open"
+	SUCCESS_HTTP_200    = "http-200"
+	SUCCESS_STREAK_ZERO = "failing-streak-zero"
+)
+
+func (i *ContainerInspect) IPAddress() string {
+	for _, network := range i.NetworkSettings.Networks {
+		if network.IPAddress != "" {
+			return network.IPAddress
+		}
+	}
+
+	return ""
+}
+
+func (i *ContainerInspect) FirstExposedPort() string {
+	for port := range i.Config.ExposedPorts {
+		if strings.HasSuffix(port, "/tcp") {
+			return strings.TrimSuffix(port, "/tcp")
+		}
+	}
+
+	return ""
+}
+
+func (c *Client) successStrategy(container Container) string {
+	if strategy := container.Labels["autoheal.success"]; strategy != "" {
+		return strategy
+	}
+	if c.cfg.Success != "" {
+		return c.cfg.Success
+	}
+
+	switch {
+	case container.Labels["autoheal.verify.path"] != "":
+		return SUCCESS_HTTP_200
+	case container.Labels["autoheal.verify.port"] != "":
+		return SUCCESS_PORT_OPEN
+	}
+
+	return ""
+}
+
+func (c *Client) verifyTarget(container Container) (string, error) {
+	inspect, err := c.inspectContainer(container.Id)
+	if err != nil {
+		return "", err
+	}
+
+	port := container.Labels["autoheal.verify.port"]
+	if port == "" {
+		port = inspect.FirstExposedPort()
+	}
+	ip := inspect.IPAddress()
+	if ip == "" || port == "" {
+		return "", fmt.Errorf("no address to verify the container on")
+	}
+
+	return net.JoinHostPort(ip, port), nil
+}
+
+func (c *Client) checkSuccess(container Container, strategy string) error {
+	switch strategy {
+	case SUCCESS_PORT_OPEN, SUCCESS_HTTP_200:
+		addr, err := c.verifyTarget(container)
+		if err != nil {
+			return err
+		}
+		if strategy == SUCCESS_HTTP_200 {
+			return c.probe("http://" + addr + "/" + strings.TrimPrefix(container.Labels["autoheal.verify.path"], "/"))
+		}
+		conn, err := net.DialTimeout("tcp", addr, c.cfg.RequestTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	inspect, err := c.inspectContainer(container.Id)
+	if err != nil {
+		return err
+	}
+
+	switch strategy {
+	case SUCCESS_RUNNING:
+		if !inspect.State.Running {
+			return fmt.Errorf("container is %s", inspect.State.Status)
+		}
+	case SUCCESS_HEALTHY:
+		if status := inspect.HealthStatus(); status != "healthy" {
+			return fmt.Errorf("container health is %q", status)
+		}
+	case SUCCESS_STREAK_ZERO:
+		if inspect.State.Health != nil && inspect.State.Health.FailingStreak > 0 {
+			return fmt.Errorf("container has a failing streak of %d", inspect.State.Health.FailingStreak)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) verify(container Container) error {
+	strategy := c.successStrategy(container)
+	switch strategy {
+	case "":
+		return nil
+	case SUCCESS_RUNNING, SUCCESS_HEALTHY, SUCCESS_PORT_OPEN, SUCCESS_HTTP_200, SUCCESS_STREAK_ZERO:
+	default:
+		return fmt.Errorf("unknown success strategy %q", strategy)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.checkSuccess(container, strategy)
+		if err == nil || attempt >= c.cfg.VerifyRetries {
+			return err
+		}
+		time.Sleep(c.cfg.VerifyDelay)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestVersionEndpoint(t *testing.T) {
+	version, commit, buildDate = "1.2.3", "abc123", "2024-01-02T03:04:05Z"
+	defer func() { version, commit, buildDate = "dev", "unknown", "unknown" }()
+
+	c := newTestClient(t, nil)
+	c.stats.Started = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	w := httptest.NewRecorder()
+	c.metricsMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("/version answered %d", w.Code)
+	}
+
+	var got map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"version": "1.2.3", "commit": "abc123", "build_date": "2024-01-02T03:04:05Z", "started": "2024-01-02T03:04:05Z"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	c.metricsMux().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/version", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /version answered %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestBuildInfoGauge(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(buildInfo)
+	buildInfo.WithLabelValues("1.2.3", "abc123", "2024-01-02").Set(1)
+	defer buildInfo.Reset()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 1 || len(families[0].Metric) != 1 {
+		t.Fatalf("gathered %v, want a single build_info series", families)
+	}
+
+	m := families[0].Metric[0]
+	labels := map[string]string{}
+	for _, l := range m.Label {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if families[0].GetName() != "build_info" || m.Gauge.GetValue() != 1 || labels["version"] != "1.2.3" || labels["commit"] != "abc123" || labels["build_date"] != "2024-01-02" {
+		t.Errorf("build_info = %s %v %v, want 1 with version, commit and build_date", families[0].GetName(), labels, m.Gauge.GetValue())
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	WEBHOOK_FORMAT_TEXT    = "text"
+	WEBHOOK_FORMAT_SLACK   = "slack"
+	WEBHOOK_FORMAT_DISCORD = "discord"
+	WEBHOOK_FORMAT_JSON    = "json"
+
+	DISCORD_MAX_CONTENT = 2000
+)
+
+type Event struct {
+	Time      string `json:"time"`
+	Container string `json:"container"`
+	Id        string `json:"id"`
+	Reason    string `json:"reason"`
+	Result    string `json:"result"`
+	Message   string `json:"message"`
+	Failures  int    `json:"failures,omitempty"`
+	EventId   string `json:"event_id,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Logs      string `json:"logs,omitempty"`
+}
+
+func newEventId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func (e Event) String() string {
+	if e.Container == "" {
+		return fmt.Sprintf("%s %s.", e.Time, e.Message)
+	}
+
+	reason := e.Reason
+	if reason == "" {
+		reason = REASON_UNHEALTHY
+	}
+
+	if e.EventId != "" {
+		return fmt.Sprintf("%s Container %s (%s) %s. %s. [event %s]", e.Time, e.Container, e.Id, reason, e.Message, e.EventId)
+	}
+
+	return fmt.Sprintf("%s Container %s (%s) %s. %s.", e.Time, e.Container, e.Id, reason, e.Message)
+}
+
+func (e Event) withLogs(limit int) string {
+	text := e.String()
+	if e.Logs == "" {
+		return text
+	}
+
+	logs := e.Logs
+	if room := limit - len(text) - len("\n```\n\n```"); limit > 0 && len(logs) > room {
+		logs = logs[max(len(logs)-room, 0):]
+		if i := strings.IndexByte(logs, '\n'); i >= 0 {
+			logs = logs[i+1:]
+		}
+	}
+
+	return text + "\n```\n" + logs + "\n```"
+}
+
+func (c *Client) loadTemplate() error {
+	text, name := c.cfg.WebHookTemplate, "WEBHOOK_TEMPLATE"
+	if c.cfg.WebHookTmplFile != "" {
+		b, err := os.ReadFile(c.cfg.WebHookTmplFile)
+		if err != nil {
+			return fmt.Errorf("failed to read webhook template: %w", err)
+		}
+		text, name = string(b), c.cfg.WebHookTmplFile
+	}
+
+	tmpl, err := parseFormat(name, text)
+	if err != nil {
+		return err
+	}
+	c.tmpl.Store(tmpl)
+
+	return nil
+}
+
+func (c *Client) payload(e Event) ([]byte, error) {
+	if tmpl := c.tmpl.Load(); tmpl != nil {
+		return render(tmpl, e)
+	}
+
+	switch c.cfg.WebHookFormat {
+	case WEBHOOK_FORMAT_SLACK:
+		return json.Marshal(map[string]string{"text": e.withLogs(0)})
+	case WEBHOOK_FORMAT_DISCORD:
+		return json.Marshal(map[string]string{"content": e.withLogs(DISCORD_MAX_CONTENT)})
+	case WEBHOOK_FORMAT_JSON:
+		return json.Marshal(e)
+	}
+
+	return json.Marshal(map[string]string{c.cfg.WebHookKey: e.withLogs(0)})
+}
+
+func (c *Client) duplicate(e Event) bool {
+	if c.cfg.WebHookDedupWindow <= 0 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(e.Container + "\x00" + e.Result + "\x00" + e.Message))
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, sent := range c.sent {
+		if now.Sub(sent) >= c.cfg.WebHookDedupWindow {
+			delete(c.sent, k)
+		}
+	}
+
+	if _, ok := c.sent[sum]; ok {
+		return true
+	}
+	c.sent[sum] = now
+
+	return false
+}
+
+func (c *Client) notify(e Event) error {
+	c.logEvent(e)
+
+	if c.cfg.EventBrokerOn.accepts(e) {
+		c.pubQueue.enqueue(e)
+	}
+	if c.cfg.K8sEventsOn.accepts(e) {
+		c.k8sQueue.enqueue(e)
+	}
+
+	return c.webhook(e)
+}
+
+func (c *Client) webhook(e Event) error {
+	if !c.cfg.WebHookOn.accepts(e) {
+		return nil
+	}
+
+	if e.Result == RESULT_FAILURE && e.Failures < c.cfg.WebHookFailureThreshold {
+		warnf("Suppressed webhook notification after %d of %d consecutive failures.\n", e.Failures, c.cfg.WebHookFailureThreshold)
+		return nil
+	}
+
+	if c.cfg.WebHookUrl != "" {
+		if c.duplicate(e) {
+			infof("Suppressed duplicate webhook notification.\n")
+			return nil
+		}
+
+		body, err := c.payload(e)
+		if err != nil {
+			return err
+		}
+
+		return c.enqueueWebhook(body)
+	}
+
+	return nil
+}
+
+func (c *Client) startWebhooks() {
+	c.webhooks = make(chan []byte, c.cfg.WebHookQueueSize)
+	c.webhooksDone = make(chan struct{})
+
+	go func() {
+		defer close(c.webhooksDone)
+		for body := range c.webhooks {
+			if err := c.fanOutWebhook(body); err != nil {
+				errorf("Failed to call webhook. %s\n", err)
+			}
+		}
+	}()
+}
+
+func (c *Client) enqueueWebhook(body []byte) error {
+	c.webhooksMu.RLock()
+	defer c.webhooksMu.RUnlock()
+
+	if c.webhooks == nil {
+		return c.fanOutWebhook(body)
+	}
+
+	select {
+	case c.webhooks <- body:
+		return nil
+	default:
+		if c.cfg.MetricsEnabled {
+			c.webhookDropped.Add(c.ctx, 1)
+		}
+		return fmt.Errorf("webhook queue is full (%d), dropping the notification", c.cfg.WebHookQueueSize)
+	}
+}
+
+func (c *Client) drainWebhooks() {
+	c.webhooksMu.Lock()
+	webhooks := c.webhooks
+	c.webhooks = nil
+	c.webhooksMu.Unlock()
+
+	if webhooks != nil {
+		close(webhooks)
+		<-c.webhooksDone
+	}
+}
+
+func (c *Client) fanOutWebhook(body []byte) error {
+	errs := make([]error, len(c.webhookUrls))
+	var wg sync.WaitGroup
+	for i, target := range c.webhookUrls {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			errs[i] = c.deliverWebhook(target, body)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func webhookHost(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return REDACTED
+	}
+
+	return u.Host
+}
+
+func (c *Client) deliverWebhook(target string, body []byte) error {
+	err := c.postWebhook(target, body)
+
+	backoff := c.cfg.WebHookRetryBackoff
+	for attempt := 1; err != nil && attempt <= c.cfg.WebHookRetries; attempt++ {
+		warnf("Failed to call webhook %s, retrying in %s (%d/%d). %s\n", webhookHost(target), backoff, attempt, c.cfg.WebHookRetries, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			return err
+		}
+
+		err = c.postWebhook(target, body)
+		backoff *= 2
+	}
+
+	if err != nil && c.cfg.MetricsEnabled {
+		c.webhookFailures.Add(c.ctx, 1, attribute.String("webhook", webhookHost(target)))
+	}
+
+	return err
+}
+
+func (c *Client) postWebhook(target string, body []byte) error {
+	request, err := http.NewRequest(c.cfg.WebHookMethod, target, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", CONTENT_TYPE)
+	for k, v := range c.webhookHeaders {
+		request.Header[k] = v
+	}
+	if c.cfg.WebHookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(c.cfg.WebHookSecret))
+		mac.Write(body)
+		request.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	response, err := c.httpw.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("webhook returned status %d: %s", response.StatusCode, bytes.TrimSpace(message))
+	}
+	io.Copy(io.Discard, response.Body)
+
+	return nil
+}
+
+func parseHeaders(s string) (http.Header, error) {
+	headers := http.Header{}
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ';' }) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected Key: Value", line)
+		}
+		headers.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+
+	return headers, nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newWebhookClient(t *testing.T, d *fakeAPI, env map[string]string, urls ...string) *Client {
+	t.Helper()
+	env["WEBHOOK_URL"] = strings.Join(urls, ",")
+	c := newFakeClient(t, d, env)
+	c.webhookUrls = urls
+	c.startWebhooks()
+	t.Cleanup(c.drainWebhooks)
+
+	return c
+}
+
+func TestDuplicateIgnoresTimeAndEventId(t *testing.T) {
+	c := newTestClient(t, map[string]string{"WEBHOOK_DEDUP_WINDOW": "60"})
+
+	e := Event{Time: "2024.01.01 00:00:00", Container: "/web", Id: "0123456789ab", Result: RESULT_SUCCESS, Message: "Successfully restarted the container", EventId: "aaaaaaaaaaaaaaaa"}
+	if c.duplicate(e) {
+		t.Fatal("first event reported as a duplicate")
+	}
+
+	e.Time, e.EventId = "2024.01.01 00:00:05", "bbbbbbbbbbbbbbbb"
+	if !c.duplicate(e) {
+		t.Error("repeat of the same event with a new time and event id was not suppressed")
+	}
+
+	e.Result, e.Message = RESULT_FAILURE, "Failed to restart the container"
+	if c.duplicate(e) {
+		t.Error("event with a different result reported as a duplicate")
+	}
+}
+
+func TestSlowWebhookDoesNotBlockLoop(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer hook.Close()
+	defer close(release)
+
+	d := &fakeAPI{unhealthy: []Container{{Id: "0123456789abcdef", Names: []string{"/web"}, State: "running"}}}
+	c := newWebhookClient(t, d, map[string]string{"WEBHOOK_RETRIES": "0"}, hook.URL)
+
+	done := make(chan struct{})
+	go func() {
+		c.runOnce(c.ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runOnce blocked on a slow webhook")
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was never delivered")
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	const secret = "s3cret"
+	got := make(chan bool, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		got <- hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+	}))
+	defer hook.Close()
+
+	c := newFakeClient(t, &fakeAPI{}, map[string]string{"WEBHOOK_URL": hook.URL, "WEBHOOK_SIGNING_SECRET": secret})
+	c.webhookUrls = []string{hook.URL}
+	if err := c.webhook(Event{Time: "2024.01.01 00:00:00", Container: "/web", Id: "0123456789ab", Result: RESULT_SUCCESS, Message: "Successfully restarted the container"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !<-got {
+		t.Error("X-Signature does not match the HMAC-SHA256 of the body")
+	}
+}
+
+func TestWebhookFanOut(t *testing.T) {
+	bodies := make(chan string, 2)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	})
+	first, second := httptest.NewServer(handler), httptest.NewServer(handler)
+	defer first.Close()
+	defer second.Close()
+
+	c := newFakeClient(t, &fakeAPI{}, map[string]string{"WEBHOOK_URL": first.URL + "," + second.URL})
+	c.webhookUrls = []string{first.URL, second.URL}
+	if err := c.webhook(Event{Time: "2024.01.01 00:00:00", Container: "/web", Id: "0123456789ab", Result: RESULT_SUCCESS, Message: "Successfully restarted the container"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if body := <-bodies; !strings.Contains(body, "Container /web (0123456789ab)") {
+			t.Errorf("webhook %d received %q", i, body)
+		}
+	}
+}
+
+func TestPayloadIncludesLogs(t *testing.T) {
+	e := Event{Time: "2024.01.01 00:00:00", Container: "/web", Id: "0123456789ab", Result: RESULT_SUCCESS, Message: "Successfully restarted the container", Logs: "FATAL out of connections"}
+
+	for _, format := range []string{WEBHOOK_FORMAT_TEXT, WEBHOOK_FORMAT_SLACK, WEBHOOK_FORMAT_DISCORD, WEBHOOK_FORMAT_JSON} {
+		c := newTestClient(t, map[string]string{"WEBHOOK_FORMAT": format})
+		body, err := c.payload(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var doc map[string]string
+		if err := json.Unmarshal(body, &doc); err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, v := range doc {
+			found = found || strings.Contains(v, e.Logs)
+		}
+		if !found {
+			t.Errorf("%s payload has no log tail: %s", format, body)
+		}
+	}
+}
+
+func TestDiscordPayloadFits(t *testing.T) {
+	e := Event{Time: "2024.01.01 00:00:00", Container: "/web", Id: "0123456789ab", Result: RESULT_SUCCESS, Message: "Successfully restarted the container", Logs: strings.Repeat("a noisy log line\n", 500) + "FATAL last line"}
+
+	content := e.withLogs(DISCORD_MAX_CONTENT)
+	if len(content) > DISCORD_MAX_CONTENT {
+		t.Errorf("discord content is %d bytes, over the %d limit", len(content), DISCORD_MAX_CONTENT)
+	}
+	if !strings.Contains(content, "FATAL last line") || !strings.HasPrefix(content, e.String()) {
+		t.Errorf("discord content lost the message or the newest log line:\n%s", content)
+	}
+}
+
+func TestWebhookHeaders(t *testing.T) {
+	got := make(chan http.Header, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header
+	}))
+	defer hook.Close()
+
+	c := newFakeClient(t, &fakeAPI{}, map[string]string{"WEBHOOK_URL": hook.URL, "WEBHOOK_RETRIES": "0"})
+	c.webhookUrls = []string{hook.URL}
+	var err error
+	if c.webhookHeaders, err = parseHeaders("Authorization: Bearer t0ken; X-Api-Key: k3y\nX-Team: ops"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.webhook(Event{Time: "2024.01.01 00:00:00", Container: "/web", Id: "0123456789ab", Result: RESULT_SUCCESS, Message: "Successfully restarted the container"}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := <-got
+	for k, v := range map[string]string{"Authorization": "Bearer t0ken", "X-Api-Key": "k3y", "X-Team": "ops", "Content-Type": CONTENT_TYPE} {
+		if h.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, h.Get(k), v)
+		}
+	}
+
+	if _, err := parseHeaders("Authorization Bearer t0ken"); err == nil {
+		t.Error("parsed a header without a colon")
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  string
+		posts    int32
+		failures int64
+	}{
+		{"recovers", "2", 3, 0},
+		{"exhausted", "1", 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posts atomic.Int32
+			hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if posts.Add(1) <= 2 {
+					http.Error(w, "unavailable", http.StatusInternalServerError)
+				}
+			}))
+			defer hook.Close()
+
+			c, reader := newMeteredClient(t, map[string]string{"WEBHOOK_URL": hook.URL, "WEBHOOK_RETRIES": tt.retries, "WEBHOOK_RETRY_BACKOFF_MS": "1"})
+			err := c.deliverWebhook(hook.URL, []byte(`{"text": "Container /web (0123456789ab) restarted"}`))
+			if (err != nil) != (tt.failures > 0) {
+				t.Errorf("deliverWebhook = %v", err)
+			}
+			if n := posts.Load(); n != tt.posts {
+				t.Errorf("posted %d times, want %d", n, tt.posts)
+			}
+			if got := counters(t, reader, "webhook_failures"); got[""] != tt.failures {
+				t.Errorf("webhook_failures = %v, want %d", got, tt.failures)
+			}
+		})
+	}
+}
+
+func TestWebhookFormats(t *testing.T) {
+	e := Event{Time: "2024.01.01 00:00:00", Container: "/web", Id: "0123456789ab", Reason: REASON_UNHEALTHY, Result: RESULT_SUCCESS, Message: "Successfully restarted the container"}
+	text := e.String()
+	tests := []struct {
+		format, method string
+		want           map[string]string
+	}{
+		{WEBHOOK_FORMAT_TEXT, "", map[string]string{"msg": text}},
+		{WEBHOOK_FORMAT_SLACK, "", map[string]string{"text": text}},
+		{WEBHOOK_FORMAT_DISCORD, "put", map[string]string{"content": text}},
+		{WEBHOOK_FORMAT_JSON, "PUT", map[string]string{"time": e.Time, "container": "/web", "id": "0123456789ab", "reason": REASON_UNHEALTHY, "result": RESULT_SUCCESS, "message": e.Message}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			type request struct {
+				method string
+				body   map[string]string
+			}
+			got := make(chan request, 1)
+			hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]string
+				json.NewDecoder(r.Body).Decode(&body)
+				got <- request{r.Method, body}
+			}))
+			defer hook.Close()
+
+			env := map[string]string{"WEBHOOK_URL": hook.URL, "WEBHOOK_FORMAT": tt.format, "WEBHOOK_KEY": "msg"}
+			if tt.method != "" {
+				env["WEBHOOK_METHOD"] = tt.method
+			}
+			c := newFakeClient(t, &fakeAPI{}, env)
+			c.webhookUrls = []string{hook.URL}
+			if err := c.webhook(e); err != nil {
+				t.Fatal(err)
+			}
+
+			r := <-got
+			want := http.MethodPost
+			if tt.method != "" {
+				want = http.MethodPut
+			}
+			if r.method != want {
+				t.Errorf("method = %s, want %s", r.method, want)
+			}
+			if len(r.body) != len(tt.want) {
+				t.Errorf("body = %v, want %v", r.body, tt.want)
+			}
+			for k, v := range tt.want {
+				if r.body[k] != v {
+					t.Errorf("%s = %q, want %q", k, r.body[k], v)
+				}
+			}
+		})
+	}
+}